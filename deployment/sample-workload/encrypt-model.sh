@@ -3,95 +3,29 @@
 # Remove extra "/" at end of url
 KBS_URL="${KBS_URL%/}"
 
-# Generate kbs auth token
-BEARER_TOKEN=$(curl -s --location ${KBS_URL}/token \
-              --header 'Accept: application/jwt' \
-              --header 'Content-Type: application/json' \
-              --data '{
-                  "username": "'"${KBS_ADMIN}"'",
-                  "password": "'"${KBS_PASSWORD}"'"
-              }' -k)
-if [ $? -ne 0 ]; then
-    echo "Error: Failed to retrieve token from KBS"
-    exit 1
-fi
-
-# Create Key transfer policy
-KEY_TRANSFER_OUTPUT=$(curl -s --location ${KBS_URL}/key-transfer-policies \
-                      --header 'Content-Type: application/json' \
-                      --header 'Accept: application/json' \
-                      --header "Authorization: Bearer ${BEARER_TOKEN}" \
-                      --data '{
-                          "attestation_type": "TDX",
-                          "tdx": {
-                              "attributes": {
-                                  "enforce_tcb_upto_date": false
-                              }
-                          }
-                      }' -k)
-if [ $? -ne 0 ]; then
-    echo "Error: Failed to create key transfer policy on KBS"
-    exit 1
-fi
-
-# Extract transfer_policy_id from key transfer policy creation output
-transfer_policy_id=$(echo "$KEY_TRANSFER_OUTPUT" | jq -r '.id')
-if [ $? -ne 0 ]; then
-    echo "Error: Failed to extract transfer_policy_id from key transfer policy creation output"
-    exit 1
-fi
-
-# Create AES 256 Key on KBS with transfer_policy_id
-KEY_OUTPUT=$(curl -s --location ${KBS_URL}/keys \
---header 'Content-Type: application/json' \
---header 'Accept: application/json' \
---header "Authorization: Bearer ${BEARER_TOKEN}" \
---data '{
-    "key_information": {
-        "algorithm": "AES",
-        "key_length": 256
-    },
-    "transfer_policy_id": "'"$transfer_policy_id"'"
-}' -k )
-if [ $? -ne 0 ]; then
-    echo "Error: Failed to create key on KBS"
-    exit 1
+KBS_TLS_OPTION=""
+if [ "${SKIP_TLS_VERIFICATION,,}" == "true" ]; then
+    KBS_TLS_OPTION="--kbs-skip-tls-verify"
 fi
 
-# Extract key_id from key creation output
-key_id=$(echo "$KEY_OUTPUT" | jq -r '.id')
+# Create a key transfer policy and an AES 256 key on KBS, fetch the key wrapped
+# with the public key of keypair.pem and encrypt the datafile with it.
+# The encryptor reads the KBS credentials from KBS_ADMIN and KBS_PASSWORD.
+export KBS_ADMIN KBS_PASSWORD
+/usr/local/bin/encrypt encrypt --in diabetes-linreg.model --out model.enc \
+    --key-file keypair.pem --kbs-url "${KBS_URL}" ${KBS_TLS_OPTION}
 if [ $? -ne 0 ]; then
-    echo "Error: Failed to extract key_id from key creation output"
+    echo "Error: Failed to encrypt the datafile with a key from KBS"
     exit 1
 fi
 
-# Read public key from file
-public_key=$(cat public.crt)
-
-# Fetch the wrapped key
-WRAPPED_KEY_OUTPUT=$(curl -s --location ${KBS_URL}/keys/$key_id \
---header 'Content-Type: application/x-pem-file' \
---header 'Accept: application/json' \
---header "Authorization: Bearer ${BEARER_TOKEN}" \
---data "${public_key}" -k)
-if [ $? -ne 0 ]; then
-    echo "Error: Failed to retrieve DEK from KBS"
+# Extract key_id from the encrypted model header
+key_id=$(/usr/local/bin/encrypt inspect --in model.enc | jq -r '.key_id // empty')
+if [ $? -ne 0 ] || [ -z "$key_id" ]; then
+    echo "Error: Failed to extract key_id from the encrypted model"
     exit 1
 fi
 
-# Extract DEK from KBS retrieval output
-wrapped_key=$(echo "$WRAPPED_KEY_OUTPUT" | jq -r '.wrapped_key')
-if [ $? -ne 0 ]; then
-    echo "Error: Failed to extract DEK from key retrieval output"
-    exit 1
-fi
-
-# Write wrapped dek to file
-echo "$wrapped_key" > wrappedKey
-
-# Encrypt the datafile using encryptor
-/usr/local/bin/encrypt diabetes-linreg.model keypair.pem wrappedKey
-
 # Push the encrypted datafile under /etc/
 cp model.enc /etc/
 
@@ -0,0 +1,92 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"io"
+
+	"github.com/intel/trustauthority-samples/modelcrypt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// errIntegrity marks failures to authenticate the encrypted model
+var errIntegrity = errors.New("Encrypted model failed integrity verification")
+
+// decryptError marks authentication failures of the encrypted model with
+// errIntegrity and keeps the cause of all other errors, e.g. I/O errors
+func decryptError(err error, msg string) error {
+	if errors.Cause(err) == modelcrypt.ErrAuthentication {
+		return errors.Wrap(errIntegrity, err.Error())
+	}
+	return errors.Wrap(err, msg)
+}
+
+// Decrypt reads an encrypted model in any supported format from in and writes
+// the plaintext to out. The returned header is nil for legacy models.
+func Decrypt(in io.Reader, out io.Writer, key []byte) (*modelcrypt.Header, error) {
+
+	header, plainText, err := modelcrypt.Decrypt(in, key)
+	if err != nil {
+		return nil, decryptError(err, "Error during decrypting the data")
+	}
+	defer plainText.Close()
+
+	w := &errWriter{w: out}
+	if _, err = io.Copy(w, plainText); err != nil {
+		if w.err != nil {
+			return nil, errors.Wrap(err, "Error during writing the decrypted data")
+		}
+		return nil, decryptError(err, "Error during decrypting the data")
+	}
+
+	logrus.Info("Successfully decrypted data")
+	return header, nil
+}
+
+// Verify decrypts the model without keeping the plaintext to check that it
+// is authentic and matches the digest recorded in its header
+func Verify(in io.Reader, key []byte) (*modelcrypt.Header, error) {
+
+	header, plainText, err := modelcrypt.Decrypt(in, key)
+	if err != nil {
+		return nil, decryptError(err, "Error during decrypting the data")
+	}
+	defer plainText.Close()
+
+	if _, err = io.Copy(io.Discard, plainText); err != nil {
+		return nil, decryptError(err, "Error during decrypting the data")
+	}
+
+	logrus.Info("Successfully verified data")
+	return header, nil
+}
+
+// Inspect returns the header of an encrypted model without decrypting it. The
+// header is nil for legacy models.
+func Inspect(in io.Reader) (*modelcrypt.Header, error) {
+
+	header, err := modelcrypt.ReadHeader(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading the model header")
+	}
+	return header, nil
+}
+
+// errWriter records write errors so that they can be told apart from
+// decryption errors returned by io.Copy
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) Write(p []byte) (int, error) {
+	n, err := ew.w.Write(p)
+	if err != nil {
+		ew.err = err
+	}
+	return n, err
+}
@@ -12,7 +12,7 @@ import (
 	"crypto/rsa"
 	"crypto/sha512"
 	"crypto/x509"
-	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 	"io"
@@ -20,8 +20,10 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/intel/trustauthority-samples/modelcrypt"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/term"
 )
 
 // zeroizeByteArray overwrites a byte array's data with zeros
@@ -56,19 +58,147 @@ func zeroizeRSAPrivateKey(privateKey *rsa.PrivateKey) {
 	}
 }
 
-func Encrypt(modelPath string, privateKeyLocation string, encryptedFileLocation string, wrappedKey []byte) error {
+const envKeyPassphrase = "ENCRYPT_KEY_PASSPHRASE"
 
-	modelPath = filepath.Clean(modelPath)
-	model, err := os.ReadFile(modelPath)
+// Format selects the layout of the encrypted model
+type Format string
+
+const (
+	// FormatStream is the chunked format that is encrypted and decrypted
+	// without holding the model in memory
+	FormatStream Format = "stream"
+	// FormatEnvelope seals the whole model at once behind the metadata header
+	FormatEnvelope Format = "envelope"
+	// FormatLegacy is the original iv || ciphertext || tag format without a header
+	FormatLegacy Format = "legacy"
+)
+
+func ParseFormat(format string) (Format, error) {
+	switch f := Format(format); f {
+	case FormatStream, FormatEnvelope, FormatLegacy:
+		return f, nil
+	}
+	return "", errors.Errorf("Unsupported format %q, must be one of %s, %s or %s", format, FormatStream, FormatEnvelope, FormatLegacy)
+}
+
+// EncryptOptions select the output format and the metadata recorded in the
+// header of the encrypted model
+type EncryptOptions struct {
+	Format         Format
+	KeyID          string
+	KeyTransferURL string
+	ModelName      string
+	ModelVersion   string
+	// ChunkSize is the plaintext size of each encrypted chunk
+	ChunkSize int
+	// Recipients are additional KBS keys the model can be decrypted with.
+	// When set, the model is encrypted with a random content key that is
+	// wrapped for the primary key and every recipient.
+	Recipients []RecipientKey
+}
+
+// RecipientKey is a KBS key the content key of a model is wrapped with
+type RecipientKey struct {
+	Key            []byte
+	KeyID          string
+	KeyTransferURL string
+}
+
+// Encrypt reads the model from in and writes it encrypted with key to out
+func Encrypt(in io.Reader, out io.Writer, key []byte, opts EncryptOptions) error {
+
+	header := newHeader(opts)
+	if len(opts.Recipients) > 0 {
+		if opts.Format == FormatLegacy {
+			return errors.New("The legacy format does not support recipients")
+		}
+
+		cek, recipients, err := wrapForRecipients(key, opts)
+		if err != nil {
+			return err
+		}
+		defer zeroizeByteArray(cek)
+
+		key = cek
+		header.KeyID = ""
+		header.KeyTransferURL = ""
+		header.Recipients = recipients
+	}
+
+	var err error
+	switch opts.Format {
+	case FormatStream, "":
+		err = encryptStream(key, in, out, header)
+	case FormatEnvelope:
+		err = encryptEnvelope(key, in, out, header)
+	case FormatLegacy:
+		err = encryptLegacy(key, in, out)
+	default:
+		err = errors.Errorf("Unsupported format %q", opts.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	logrus.Info("Successfully encrypted data")
+	return nil
+}
+
+// encryptStream writes the model in the chunked format. When the model can be
+// rewound it is read twice, first to record its digest in the header and then
+// to encrypt it. Models read from a pipe are written without a digest and are
+// protected by the chunk authentication only.
+func encryptStream(key []byte, model io.Reader, out io.Writer, header modelcrypt.Header) error {
+
+	if seeker, ok := model.(io.Seeker); ok {
+		if start, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			digest := sha512.New384()
+			if _, err := io.Copy(digest, model); err != nil {
+				return errors.Wrap(err, "Error reading the data file")
+			}
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return errors.Wrap(err, "Error reading the data file")
+			}
+			header.PlaintextSHA384 = hex.EncodeToString(digest.Sum(nil))
+		}
+	}
+
+	w, err := modelcrypt.NewWriter(out, key, header)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(w, model); err != nil {
+		return errors.Wrap(err, "Error during writing the encrypted data")
+	}
+	return w.Close()
+}
+
+func encryptEnvelope(key []byte, model io.Reader, out io.Writer, header modelcrypt.Header) error {
+	data, err := io.ReadAll(model)
 	if err != nil {
 		return errors.Wrap(err, "Error reading the data file")
 	}
+	defer zeroizeByteArray(data)
 
-	key, err := UnwrapKey(wrappedKey, privateKeyLocation)
+	header.ChunkSize = 0
+	encryptedData, err := modelcrypt.Seal(key, header, data)
 	if err != nil {
-		return errors.Wrap(err, "Error while unwrapping the key")
+		return err
+	}
+
+	if _, err = out.Write(encryptedData); err != nil {
+		return errors.Wrap(err, "Error during writing the encrypted data")
 	}
-	defer zeroizeByteArray(key)
+	return nil
+}
+
+func encryptLegacy(key []byte, model io.Reader, out io.Writer) error {
+	data, err := io.ReadAll(model)
+	if err != nil {
+		return errors.Wrap(err, "Error reading the data file")
+	}
+	defer zeroizeByteArray(data)
 
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -86,71 +216,85 @@ func Encrypt(modelPath string, privateKeyLocation string, encryptedFileLocation
 		return errors.Wrap(err, "Error creating random IV value")
 	}
 
-	encryptedData := gcm.Seal(iv, iv, model, nil)
-	err = os.WriteFile(encryptedFileLocation, encryptedData, 0600)
-	if err != nil {
-		return errors.Wrap(err, "Error during writing the encrypted data to file")
+	if _, err = out.Write(gcm.Seal(iv, iv, data, nil)); err != nil {
+		return errors.Wrap(err, "Error during writing the encrypted data")
 	}
-
-	logrus.Info("Successfully encrypted data")
 	return nil
 }
 
-func UnwrapKey(wrappedKey []byte, privateKeyLocation string) ([]byte, error) {
+func newHeader(opts EncryptOptions) modelcrypt.Header {
+	return modelcrypt.Header{
+		KeyID:          opts.KeyID,
+		KeyTransferURL: opts.KeyTransferURL,
+		ModelName:      opts.ModelName,
+		ModelVersion:   opts.ModelVersion,
+		ChunkSize:      opts.ChunkSize,
+	}
+}
 
-	privateKeyLocation = filepath.Clean(privateKeyLocation)
-	privateKey, err := os.ReadFile(privateKeyLocation)
+// wrapForRecipients creates a content key and wraps it with the primary key
+// and the key of every recipient
+func wrapForRecipients(key []byte, opts EncryptOptions) ([]byte, []modelcrypt.Recipient, error) {
+	cek, err := modelcrypt.NewContentKey()
 	if err != nil {
-		return nil, errors.Wrap(err, "Error reading private key file")
+		return nil, nil, err
 	}
-	defer zeroizeByteArray(privateKey)
 
-	privateKeyBlock, _ := pem.Decode(privateKey)
-	if privateKeyBlock == nil {
-		return nil, errors.New("private key not found")
+	keys := append([]RecipientKey{{Key: key, KeyID: opts.KeyID, KeyTransferURL: opts.KeyTransferURL}}, opts.Recipients...)
+	recipients := make([]modelcrypt.Recipient, 0, len(keys))
+	for _, k := range keys {
+		recipient, err := modelcrypt.WrapContentKey(k.Key, cek, k.KeyID, k.KeyTransferURL)
+		if err != nil {
+			zeroizeByteArray(cek)
+			return nil, nil, errors.Wrapf(err, "Error wrapping content key for key %q", k.KeyID)
+		}
+		recipients = append(recipients, recipient)
 	}
-	defer zeroizeByteArray(privateKeyBlock.Bytes)
+	return cek, recipients, nil
+}
 
-	pri, err := x509.ParsePKCS8PrivateKey(privateKeyBlock.Bytes)
-	if err != nil {
-		return nil, errors.Wrap(err, "Error decoding private key")
-	}
-	defer zeroizeRSAPrivateKey(pri.(*rsa.PrivateKey))
+// LoadPrivateKey reads the PEM encoded RSA private key the data encryption key
+// is wrapped with. The passphrase of encrypted keys is read from the
+// ENCRYPT_KEY_PASSPHRASE environment variable or prompted for on the terminal.
+func LoadPrivateKey(privateKeyLocation string) (*rsa.PrivateKey, error) {
 
-	decryptedKey, err := rsa.DecryptOAEP(sha512.New384(), rand.Reader, pri.(*rsa.PrivateKey), wrappedKey, nil)
+	privateKeyLocation = filepath.Clean(privateKeyLocation)
+	privateKey, err := os.ReadFile(privateKeyLocation)
 	if err != nil {
-		return nil, errors.Wrap(err, "Error while decrypting the key")
+		return nil, errors.Wrap(err, "Error reading private key file")
 	}
+	defer zeroizeByteArray(privateKey)
 
-	logrus.Info("Successfully unwrapped key")
-	return decryptedKey, nil
+	return modelcrypt.ParsePrivateKey(privateKey, readPassphrase)
 }
 
-func main() {
-
-	if len(os.Args) < 4 {
-		err := errors.New("Invalid usage of encrypt")
-		fmt.Println("./encrypt data-file private-key-file wrapped-key-file: ", err)
-		os.Exit(1)
+// readPassphrase returns the private key passphrase from the environment or
+// prompts for it on the controlling terminal, which also works when stdin
+// carries the model
+func readPassphrase() ([]byte, error) {
+	if pass, ok := os.LookupEnv(envKeyPassphrase); ok {
+		return []byte(pass), nil
 	}
 
-	wrappedKeyLocation := filepath.Clean(os.Args[3])
-	wrappedKey, err := os.ReadFile(wrappedKeyLocation)
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
 	if err != nil {
-		fmt.Println("Error reading wrapped key file: ", err)
-		os.Exit(1)
+		return nil, errors.Errorf("Private key is encrypted, set %s or run from a terminal", envKeyPassphrase)
 	}
+	defer tty.Close()
 
-	key, err := base64.StdEncoding.DecodeString(string(wrappedKey))
-	if err != nil {
-		fmt.Println("Error decoding the data encryption key: ", err)
-		os.Exit(1)
-	}
+	fmt.Fprint(tty, "Private key passphrase: ")
+	pass, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	return pass, err
+}
+
+// PublicKeyPEM returns the PEM encoded public key of the private key, in the
+// form KBS expects to wrap a key with
+func PublicKeyPEM(pri *rsa.PrivateKey) ([]byte, error) {
 
-	// encrypt the model with key retrieved from KBS
-	err = Encrypt(os.Args[1], os.Args[2], "model.enc", key)
+	pub, err := x509.MarshalPKIXPublicKey(&pri.PublicKey)
 	if err != nil {
-		fmt.Println("Data encryption failed: ", err)
-		os.Exit(1)
+		return nil, errors.Wrap(err, "Error encoding public key")
 	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}), nil
 }
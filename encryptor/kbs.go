@@ -0,0 +1,185 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	kbsclient "github.com/intel/kbs/v1/client"
+	"github.com/intel/trustauthority-samples/modelcrypt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	envKBSAdmin    = "KBS_ADMIN"
+	envKBSPassword = "KBS_PASSWORD"
+	envKBSToken    = "KBS_TOKEN"
+
+	kbsTimeout = 30 * time.Second
+)
+
+// kbsFlags are the options to fetch the data encryption key directly from
+// the KBS admin API instead of a wrapped key file
+type kbsFlags struct {
+	url              string
+	keyID            string
+	transferPolicyID string
+	caFile           string
+	skipTLSVerify    bool
+}
+
+func (kf *kbsFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&kf.url, "kbs-url", "", "KBS API URL, e.g. https://kbs:9443/kbs/v1. Fetches the key from KBS instead of --wrapped-key. "+
+		"Credentials are read from "+envKBSToken+" or "+envKBSAdmin+" and "+envKBSPassword)
+	fs.StringVar(&kf.keyID, "kbs-key-id", "", "ID of the KBS key to encrypt with (default: create a new AES-256 key)")
+	fs.StringVar(&kf.transferPolicyID, "kbs-transfer-policy-id", "", "key transfer policy of a newly created key (default: create a TDX policy)")
+	fs.StringVar(&kf.caFile, "kbs-ca-file", "", "PEM file with the CA certificates to verify KBS with (default: system roots)")
+	fs.BoolVar(&kf.skipTLSVerify, "kbs-skip-tls-verify", false, "skip verification of the KBS TLS certificate")
+}
+
+func (kf *kbsFlags) enabled() bool {
+	return kf.url != ""
+}
+
+// kbsKey is a data encryption key fetched from KBS
+type kbsKey struct {
+	key         []byte
+	keyID       string
+	transferURL string
+}
+
+// fetchKey creates or looks up the key in KBS, has it wrapped with the public
+// key of the local private key and unwraps it
+func (kf *kbsFlags) fetchKey(ctx context.Context, pri *rsa.PrivateKey, hash crypto.Hash) (*kbsKey, error) {
+	baseURL, err := url.Parse(kf.url)
+	if err != nil || baseURL.Scheme == "" || baseURL.Host == "" {
+		return nil, errors.Wrapf(errUsage, "invalid --kbs-url %q", kf.url)
+	}
+
+	httpClient, err := kf.httpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	token := os.Getenv(envKBSToken)
+	if token == "" {
+		username, password := os.Getenv(envKBSAdmin), os.Getenv(envKBSPassword)
+		if username == "" || password == "" {
+			return nil, errors.Wrapf(errUsage, "%s or %s and %s must be set to use --kbs-url", envKBSToken, envKBSAdmin, envKBSPassword)
+		}
+
+		token, err = kbsclient.NewKBSAdminClient(httpClient, baseURL, "").GetToken(ctx, username, password)
+		if err != nil {
+			return nil, errors.Wrap(errKey, "Error getting KBS token: "+err.Error())
+		}
+	}
+	client := kbsclient.NewKBSAdminClient(httpClient, baseURL, token)
+
+	keyID := kf.keyID
+	transferURL := baseURL.JoinPath("keys", keyID, "transfer").String()
+	if keyID == "" {
+		created, err := kf.createKey(ctx, client)
+		if err != nil {
+			return nil, errors.Wrap(errKey, err.Error())
+		}
+		keyID = created.ID
+		transferURL = baseURL.JoinPath("keys", keyID, "transfer").String()
+		if created.TransferLink != "" {
+			transferURL = created.TransferLink
+		}
+		logrus.WithField("KeyID", keyID).Info("Created key on KBS")
+	}
+
+	publicKey, err := PublicKeyPEM(pri)
+	if err != nil {
+		return nil, errors.Wrap(errKey, err.Error())
+	}
+
+	wrappedKey, err := client.GetWrappedKey(ctx, keyID, publicKey)
+	if err != nil {
+		return nil, errors.Wrap(errKey, "Error getting wrapped key from KBS: "+err.Error())
+	}
+
+	key, err := modelcrypt.UnwrapKey(pri, wrappedKey, hash)
+	if err != nil {
+		return nil, errors.Wrap(errKey, err.Error())
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"KeyID":          keyID,
+		"KeyTransferURL": transferURL,
+	}).Info("Successfully fetched key from KBS")
+	return &kbsKey{key: key, keyID: keyID, transferURL: transferURL}, nil
+}
+
+func (kf *kbsFlags) createKey(ctx context.Context, client kbsclient.KBSAdminClient) (*kbsclient.KeyResponse, error) {
+	policyID := kf.transferPolicyID
+	if policyID == "" {
+		policy, err := client.CreateKeyTransferPolicy(ctx, &kbsclient.KeyTransferPolicy{
+			AttestationType: kbsclient.AttestationTypeTDX,
+			TDX: &kbsclient.TdxPolicy{
+				Attributes: &kbsclient.TdxAttributes{EnforceTCBUptoDate: false},
+			},
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "Error creating key transfer policy on KBS")
+		}
+		policyID = policy.ID
+		logrus.WithField("TransferPolicyID", policyID).Info("Created key transfer policy on KBS")
+	}
+
+	created, err := client.CreateKey(ctx, &kbsclient.KeyRequest{
+		KeyInformation: &kbsclient.KeyInformation{
+			Algorithm: kbsclient.KeyAlgorithmAES,
+			KeyLength: 256,
+		},
+		TransferPolicyID: policyID,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating key on KBS")
+	}
+	if created.ID == "" {
+		return nil, errors.New("KBS did not return the ID of the created key")
+	}
+	return created, nil
+}
+
+func (kf *kbsFlags) httpClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: kf.skipTLSVerify,
+	}
+
+	if kf.caFile != "" {
+		caCerts, err := os.ReadFile(filepath.Clean(kf.caFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "Error reading KBS CA file")
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caCerts) {
+			return nil, errors.New("No certificates found in KBS CA file")
+		}
+	}
+
+	return &http.Client{
+		Timeout: kbsTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}, nil
+}
@@ -0,0 +1,131 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/intel/kbs/v1/client/kbstest"
+	"github.com/pkg/errors"
+)
+
+// kbsFlagsFor returns options for the stand-in KBS, trusting its certificate
+func kbsFlagsFor(t *testing.T, kbs *kbstest.Server) *kbsFlags {
+	t.Helper()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: kbs.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return &kbsFlags{url: kbs.BaseURL().String(), caFile: caFile}
+}
+
+func TestFetchKey(t *testing.T) {
+	ctx := context.Background()
+	pri, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kbs := kbstest.NewServer(t)
+	kbs.AddKey("existing", bytes.Repeat([]byte{7}, 32))
+	t.Setenv(envKBSAdmin, kbstest.Username)
+	t.Setenv(envKBSPassword, kbstest.Password)
+
+	t.Run("create key", func(t *testing.T) {
+		key, err := kbsFlagsFor(t, kbs).fetchKey(ctx, pri, crypto.SHA384)
+		if err != nil {
+			t.Fatalf("fetchKey: %v", err)
+		}
+		if key.keyID == "" || !bytes.Equal(key.key, kbs.Key(key.keyID)) {
+			t.Fatalf("fetchKey returned key %q %x", key.keyID, key.key)
+		}
+		if key.transferURL != kbs.BaseURL().JoinPath("keys", key.keyID, "transfer").String() {
+			t.Fatalf("fetchKey returned transfer URL %q", key.transferURL)
+		}
+	})
+
+	t.Run("existing key", func(t *testing.T) {
+		flags := kbsFlagsFor(t, kbs)
+		flags.keyID = "existing"
+		key, err := flags.fetchKey(ctx, pri, crypto.SHA384)
+		if err != nil {
+			t.Fatalf("fetchKey: %v", err)
+		}
+		if !bytes.Equal(key.key, kbs.Key("existing")) {
+			t.Fatalf("fetchKey returned key %x", key.key)
+		}
+	})
+
+	t.Run("token", func(t *testing.T) {
+		t.Setenv(envKBSAdmin, "")
+		t.Setenv(envKBSToken, kbstest.Token)
+		flags := kbsFlagsFor(t, kbs)
+		flags.keyID = "existing"
+		if _, err := flags.fetchKey(ctx, pri, crypto.SHA384); err != nil {
+			t.Fatalf("fetchKey: %v", err)
+		}
+	})
+}
+
+func TestFetchKeyErrors(t *testing.T) {
+	pri, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kbs := kbstest.NewServer(t)
+	kbs.AddKey("existing", bytes.Repeat([]byte{7}, 32))
+
+	tests := []struct {
+		name    string
+		setup   func(*kbsFlags)
+		env     map[string]string
+		hash    crypto.Hash
+		wantErr error
+	}{
+		{name: "no credentials", env: map[string]string{}, wantErr: errUsage},
+		{name: "invalid url", setup: func(f *kbsFlags) { f.url = "kbs" }, wantErr: errUsage},
+		{name: "wrong password", env: map[string]string{envKBSAdmin: kbstest.Username, envKBSPassword: "wrong"}, wantErr: errKey},
+		{name: "wrong token", env: map[string]string{envKBSToken: "wrong"}, wantErr: errKey},
+		{name: "unknown key", setup: func(f *kbsFlags) { f.keyID = "unknown" }, wantErr: errKey},
+		{name: "create fails", setup: func(*kbsFlags) { kbs.FailCreate(true) }, wantErr: errKey},
+		{name: "wrong oaep hash", setup: func(f *kbsFlags) { f.keyID = "existing" }, hash: crypto.SHA256, wantErr: errKey},
+		{name: "untrusted certificate", setup: func(f *kbsFlags) { f.caFile = "" }, wantErr: errKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kbs.FailCreate(false)
+			env := tt.env
+			if env == nil {
+				env = map[string]string{envKBSToken: kbstest.Token}
+			}
+			for _, name := range []string{envKBSToken, envKBSAdmin, envKBSPassword} {
+				t.Setenv(name, env[name])
+			}
+			flags := kbsFlagsFor(t, kbs)
+			if tt.setup != nil {
+				tt.setup(flags)
+			}
+			hash := tt.hash
+			if hash == 0 {
+				hash = crypto.SHA384
+			}
+
+			_, err := flags.fetchKey(context.Background(), pri, hash)
+			if errors.Cause(err) != tt.wantErr {
+				t.Fatalf("fetchKey returned %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,465 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/intel/trustauthority-samples/modelcrypt"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Exit codes returned by the encrypt command
+const (
+	exitSuccess        = 0
+	exitFailure        = 1
+	exitUsage          = 2
+	exitKeyError       = 3
+	exitIntegrityError = 4
+)
+
+const stdio = "-"
+
+const usage = `Usage: encrypt <command> [options]
+
+Commands:
+  encrypt   Encrypt a model with a data encryption key from KBS
+  decrypt   Decrypt an encrypted model
+  inspect   Print the header of an encrypted model
+  verify    Check that an encrypted model is authentic and not modified
+
+Run 'encrypt <command> -h' for the options of a command. Use '-' as file name
+to read from stdin or write to stdout.
+
+Exit codes:
+  0  success
+  1  I/O or other runtime error
+  2  invalid usage
+  3  the data encryption key could not be loaded or unwrapped
+  4  the encrypted model failed authentication: it was modified or
+     truncated, or the key is wrong
+`
+
+var (
+	// errKey marks failures to load or unwrap the data encryption key
+	errKey = errors.New("Unable to load the data encryption key")
+	// errUsage marks invalid command line arguments
+	errUsage = errors.New("Invalid usage of encrypt")
+)
+
+type command struct {
+	name string
+	run  func(args []string) error
+}
+
+var commands = []command{
+	{"encrypt", runEncrypt},
+	{"decrypt", runDecrypt},
+	{"inspect", runInspect},
+	{"verify", runVerify},
+}
+
+func main() {
+	logrus.SetOutput(os.Stderr)
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(os.Stdout, usage)
+		return exitSuccess
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+
+		err := cmd.run(args[1:])
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s failed: %v\n", cmd.name, err)
+		}
+		return exitCode(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", args[0], usage)
+	return exitUsage
+}
+
+func exitCode(err error) int {
+	switch errors.Cause(err) {
+	case nil:
+		return exitSuccess
+	case errUsage:
+		return exitUsage
+	case errKey:
+		return exitKeyError
+	case errIntegrity:
+		return exitIntegrityError
+	}
+	return exitFailure
+}
+
+// keyFlags are the options shared by all commands that need the data
+// encryption key
+type keyFlags struct {
+	keyFile    string
+	wrappedKey string
+	oaepHash   string
+}
+
+func (kf *keyFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&kf.keyFile, "key-file", "", "PEM encoded RSA private key (PKCS#1, PKCS#8 or encrypted PKCS#8) the data encryption key is wrapped with (required)")
+	fs.StringVar(&kf.wrappedKey, "wrapped-key", "", "file with the wrapped data encryption key as returned by KBS (required unless --kbs-url is set)")
+	fs.StringVar(&kf.oaepHash, "oaep-hash", "sha384", "RSA-OAEP hash the data encryption key is wrapped with: sha256, sha384 or sha512")
+}
+
+// privateKey loads the private key and parses the OAEP hash
+func (kf *keyFlags) privateKey() (*rsa.PrivateKey, crypto.Hash, error) {
+	if kf.keyFile == "" {
+		return nil, 0, errors.Wrap(errUsage, "--key-file is required")
+	}
+
+	hash, err := modelcrypt.ParseOAEPHash(kf.oaepHash)
+	if err != nil {
+		return nil, 0, errors.Wrap(errUsage, err.Error())
+	}
+
+	pri, err := LoadPrivateKey(kf.keyFile)
+	if err != nil {
+		return nil, 0, errors.Wrap(errKey, err.Error())
+	}
+	return pri, hash, nil
+}
+
+func (kf *keyFlags) load() ([]byte, error) {
+	if kf.wrappedKey == "" {
+		return nil, errors.Wrap(errUsage, "--wrapped-key is required")
+	}
+
+	pri, hash, err := kf.privateKey()
+	if err != nil {
+		return nil, err
+	}
+	defer zeroizeRSAPrivateKey(pri)
+
+	return unwrapKeyFile(pri, hash, kf.wrappedKey)
+}
+
+// unwrapKeyFile reads a wrapped key file and unwraps the key with pri
+func unwrapKeyFile(pri *rsa.PrivateKey, hash crypto.Hash, path string) ([]byte, error) {
+	wrappedKey, err := readWrappedKey(path)
+	if err != nil {
+		return nil, errors.Wrap(errKey, err.Error())
+	}
+
+	key, err := modelcrypt.UnwrapKey(pri, wrappedKey, hash)
+	if err != nil {
+		return nil, errors.Wrap(errKey, err.Error())
+	}
+
+	logrus.Info("Successfully unwrapped key")
+	return key, nil
+}
+
+// readWrappedKey accepts the KBS JSON response, its base64 wrapped_key value
+// or the raw wrapped key
+func readWrappedKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading wrapped key file")
+	}
+
+	text := strings.TrimSpace(string(data))
+	if strings.HasPrefix(text, "{") {
+		var resp struct {
+			WrappedKey string `json:"wrapped_key"`
+		}
+		if err = json.Unmarshal([]byte(text), &resp); err != nil {
+			return nil, errors.Wrap(err, "Error decoding wrapped key file")
+		}
+		text = resp.WrappedKey
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(text); err == nil {
+		return key, nil
+	}
+	return data, nil
+}
+
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: encrypt %s %s\n\nOptions:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errors.Wrap(errUsage, err.Error())
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return errors.Wrapf(errUsage, "unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+func runEncrypt(args []string) error {
+	var in, out, format string
+	var kf keyFlags
+	var kbs kbsFlags
+	var recipients recipientFlags
+	var opts EncryptOptions
+
+	fs := newFlagSet("encrypt", "--in <model> --out <encrypted-model> --key-file <private-key> (--wrapped-key <wrapped-key> | --kbs-url <url>) [--recipient <recipient>]...")
+	fs.StringVar(&in, "in", "", "model to encrypt (required)")
+	fs.StringVar(&out, "out", "", "encrypted model to write (required)")
+	fs.StringVar(&format, "format", string(FormatStream), "output format: stream, envelope or legacy")
+	fs.StringVar(&opts.KeyID, "key-id", "", "KBS key ID recorded in the model header (set from KBS with --kbs-url)")
+	fs.StringVar(&opts.KeyTransferURL, "key-transfer-url", "", "KBS key transfer URL recorded in the model header (default with --kbs-url: transfer URL of the KBS key)")
+	fs.StringVar(&opts.ModelName, "model-name", "", "model name recorded in the model header (default: input file name)")
+	fs.StringVar(&opts.ModelVersion, "model-version", "", "model version recorded in the model header")
+	fs.IntVar(&opts.ChunkSize, "chunk-size", modelcrypt.DefaultChunkSize, "plaintext size in bytes of each encrypted chunk (stream format)")
+	fs.Var(&recipients, "recipient", "additional key the model can be decrypted with, as wrapped-key=<file>[,key-id=<id>][,key-transfer-url=<url>] "+
+		"or kbs-url=<url>[,kbs-key-id=<id>][,key-transfer-url=<url>]. May be repeated")
+	kf.register(fs)
+	kbs.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var err error
+	if in == "" || out == "" {
+		return errors.Wrap(errUsage, "--in and --out are required")
+	}
+	if opts.Format, err = ParseFormat(format); err != nil {
+		return errors.Wrap(errUsage, err.Error())
+	}
+	if opts.ModelName == "" && in != stdio {
+		opts.ModelName = filepath.Base(in)
+	}
+
+	if kbs.enabled() && kf.wrappedKey != "" {
+		return errors.Wrap(errUsage, "--kbs-url excludes --wrapped-key")
+	}
+	if !kbs.enabled() && kf.wrappedKey == "" {
+		return errors.Wrap(errUsage, "--wrapped-key or --kbs-url is required")
+	}
+	if len(recipients) > 0 && opts.Format == FormatLegacy {
+		return errors.Wrap(errUsage, "--recipient is not supported with the legacy format")
+	}
+
+	pri, hash, err := kf.privateKey()
+	if err != nil {
+		return err
+	}
+	defer zeroizeRSAPrivateKey(pri)
+
+	ctx := context.Background()
+	var key []byte
+	if kbs.enabled() {
+		fetched, err := kbs.fetchKey(ctx, pri, hash)
+		if err != nil {
+			return err
+		}
+		key = fetched.key
+		opts.KeyID = fetched.keyID
+		if opts.KeyTransferURL == "" {
+			opts.KeyTransferURL = fetched.transferURL
+		}
+	} else if key, err = unwrapKeyFile(pri, hash, kf.wrappedKey); err != nil {
+		return err
+	}
+	defer zeroizeByteArray(key)
+
+	if opts.Recipients, err = recipients.keys(ctx, pri, hash, kbs); err != nil {
+		return err
+	}
+	defer zeroizeRecipientKeys(opts.Recipients)
+
+	return withFiles(in, out, func(r io.Reader, w io.Writer) error {
+		return Encrypt(r, w, key, opts)
+	})
+}
+
+func runDecrypt(args []string) error {
+	var in, out string
+	var kf keyFlags
+
+	fs := newFlagSet("decrypt", "--in <encrypted-model> --out <model> --key-file <private-key> --wrapped-key <wrapped-key>")
+	fs.StringVar(&in, "in", "", "encrypted model to decrypt (required)")
+	fs.StringVar(&out, "out", "", "decrypted model to write (required)")
+	kf.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if in == "" || out == "" {
+		return errors.Wrap(errUsage, "--in and --out are required")
+	}
+
+	key, err := kf.load()
+	if err != nil {
+		return err
+	}
+	defer zeroizeByteArray(key)
+
+	return withFiles(in, out, func(r io.Reader, w io.Writer) error {
+		_, err := Decrypt(r, w, key)
+		return err
+	})
+}
+
+func runVerify(args []string) error {
+	var in string
+	var kf keyFlags
+
+	fs := newFlagSet("verify", "--in <encrypted-model> --key-file <private-key> --wrapped-key <wrapped-key>")
+	fs.StringVar(&in, "in", "", "encrypted model to verify (required)")
+	kf.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if in == "" {
+		return errors.Wrap(errUsage, "--in is required")
+	}
+
+	key, err := kf.load()
+	if err != nil {
+		return err
+	}
+	defer zeroizeByteArray(key)
+
+	r, closeIn, err := openInput(in)
+	if err != nil {
+		return err
+	}
+	defer closeIn()
+
+	_, err = Verify(r, key)
+	return err
+}
+
+// inspectOutput is printed by the inspect command
+type inspectOutput struct {
+	Format  Format `json:"format"`
+	Version uint8  `json:"version,omitempty"`
+	*modelcrypt.Header
+}
+
+func runInspect(args []string) error {
+	var in, out string
+
+	fs := newFlagSet("inspect", "--in <encrypted-model>")
+	fs.StringVar(&in, "in", "", "encrypted model to inspect (required)")
+	fs.StringVar(&out, "out", stdio, "file to write the header to as JSON")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if in == "" {
+		return errors.Wrap(errUsage, "--in is required")
+	}
+
+	return withFiles(in, out, func(r io.Reader, w io.Writer) error {
+		header, err := Inspect(r)
+		if err != nil {
+			return err
+		}
+
+		output := inspectOutput{Format: FormatLegacy, Header: header}
+		if header != nil {
+			output.Version = header.Version
+			output.Format = FormatEnvelope
+			if header.Version == modelcrypt.FormatVersion2 {
+				output.Format = FormatStream
+			}
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(output)
+	})
+}
+
+// withFiles opens the input and output of a command. Output files are written
+// to a temporary file that only replaces the destination when fn succeeds, so
+// failed runs never leave partial output behind.
+func withFiles(in, out string, fn func(io.Reader, io.Writer) error) error {
+	r, closeIn, err := openInput(in)
+	if err != nil {
+		return err
+	}
+	defer closeIn()
+
+	if out == stdio {
+		return fn(r, os.Stdout)
+	}
+
+	out = filepath.Clean(out)
+	tmp, err := os.CreateTemp(filepath.Dir(out), "."+filepath.Base(out)+".*")
+	if err != nil {
+		return errors.Wrap(err, "Error creating output file")
+	}
+	defer func() {
+		if tmp != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = fn(r, tmp); err != nil {
+		return err
+	}
+
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "Error writing output file")
+	}
+	if err = os.Rename(tmp.Name(), out); err != nil {
+		return errors.Wrap(err, "Error writing output file")
+	}
+	tmp = nil
+	return nil
+}
+
+func openInput(in string) (io.Reader, func(), error) {
+	if in == stdio {
+		return os.Stdin, func() {}, nil
+	}
+
+	f, err := os.Open(filepath.Clean(in))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Error opening input file")
+	}
+	return f, func() { f.Close() }, nil
+}
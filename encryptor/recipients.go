@@ -0,0 +1,105 @@
+/*
+ *   Copyright (c) 2024 Intel Corporation
+ *   All rights reserved.
+ *   SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// recipientSpec is one --recipient option. The key is either read from a
+// wrapped key file or fetched from a KBS.
+type recipientSpec struct {
+	wrappedKey     string
+	keyID          string
+	keyTransferURL string
+	kbsURL         string
+	kbsKeyID       string
+}
+
+// recipientFlags collects the repeatable --recipient option
+type recipientFlags []recipientSpec
+
+func (rf *recipientFlags) String() string {
+	return ""
+}
+
+// Set parses a comma separated list of name=value pairs, e.g.
+// wrapped-key=dr.key,key-id=<id>,key-transfer-url=<url> or
+// kbs-url=https://kbs-dr:9443/kbs/v1,kbs-key-id=<id>
+func (rf *recipientFlags) Set(value string) error {
+	var spec recipientSpec
+	for _, field := range strings.Split(value, ",") {
+		name, val, ok := strings.Cut(field, "=")
+		if !ok || val == "" {
+			return errors.Errorf("invalid recipient field %q, must be name=value", field)
+		}
+		switch name {
+		case "wrapped-key":
+			spec.wrappedKey = val
+		case "key-id":
+			spec.keyID = val
+		case "key-transfer-url":
+			spec.keyTransferURL = val
+		case "kbs-url":
+			spec.kbsURL = val
+		case "kbs-key-id":
+			spec.kbsKeyID = val
+		default:
+			return errors.Errorf("unknown recipient field %q", name)
+		}
+	}
+
+	if (spec.wrappedKey == "") == (spec.kbsURL == "") {
+		return errors.New("a recipient needs either wrapped-key or kbs-url")
+	}
+	if spec.wrappedKey != "" && spec.kbsKeyID != "" {
+		return errors.New("kbs-key-id requires kbs-url")
+	}
+	*rf = append(*rf, spec)
+	return nil
+}
+
+// keys unwraps or fetches the key of every recipient. KBS recipients share
+// the credentials and TLS options of kbs.
+func (rf recipientFlags) keys(ctx context.Context, pri *rsa.PrivateKey, hash crypto.Hash, kbs kbsFlags) ([]RecipientKey, error) {
+	keys := make([]RecipientKey, 0, len(rf))
+	for _, spec := range rf {
+		key := RecipientKey{KeyID: spec.keyID, KeyTransferURL: spec.keyTransferURL}
+
+		if spec.kbsURL != "" {
+			kbs.url, kbs.keyID, kbs.transferPolicyID = spec.kbsURL, spec.kbsKeyID, ""
+			fetched, err := kbs.fetchKey(ctx, pri, hash)
+			if err != nil {
+				zeroizeRecipientKeys(keys)
+				return nil, err
+			}
+			key.Key, key.KeyID = fetched.key, fetched.keyID
+			if key.KeyTransferURL == "" {
+				key.KeyTransferURL = fetched.transferURL
+			}
+		} else {
+			k, err := unwrapKeyFile(pri, hash, spec.wrappedKey)
+			if err != nil {
+				zeroizeRecipientKeys(keys)
+				return nil, err
+			}
+			key.Key = k
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func zeroizeRecipientKeys(keys []RecipientKey) {
+	for _, k := range keys {
+		zeroizeByteArray(k.Key)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	HTTPHeaderKeyAuthorization     = "Authorization"
+	HTTPHeaderValueApplicationJwt  = "application/jwt"
+	HTTPHeaderValueApplicationXPEM = "application/x-pem-file"
+
+	AttestationTypeTDX = "TDX"
+	KeyAlgorithmAES    = "AES"
+)
+
+// KBSAdminClient covers the KBS admin API used to provision keys. The context
+// is attached to the outgoing requests.
+type KBSAdminClient interface {
+	GetToken(ctx context.Context, username, password string) (string, error)
+	CreateKeyTransferPolicy(context.Context, *KeyTransferPolicy) (*KeyTransferPolicy, error)
+	CreateKey(context.Context, *KeyRequest) (*KeyResponse, error)
+	GetWrappedKey(ctx context.Context, keyID string, publicKey []byte) ([]byte, error)
+}
+
+type kbsAdminClient struct {
+	kbsClient
+	Token string
+}
+
+// NewKBSAdminClient returns a client for the KBS admin API rooted at baseURL,
+// e.g. https://kbs:9443/kbs/v1. The token is the bearer token returned by
+// GetToken and may be empty for GetToken itself.
+func NewKBSAdminClient(client *http.Client, baseURL *url.URL, token string) KBSAdminClient {
+	return &kbsAdminClient{
+		kbsClient: kbsClient{
+			Client:  client,
+			BaseURL: baseURL,
+		},
+		Token: token,
+	}
+}
+
+type TokenRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type KeyTransferPolicy struct {
+	ID              string     `json:"id,omitempty"`
+	AttestationType string     `json:"attestation_type"`
+	TDX             *TdxPolicy `json:"tdx,omitempty"`
+}
+
+type TdxPolicy struct {
+	Attributes *TdxAttributes `json:"attributes,omitempty"`
+	PolicyIds  []string       `json:"policy_ids,omitempty"`
+}
+
+type TdxAttributes struct {
+	EnforceTCBUptoDate bool `json:"enforce_tcb_upto_date"`
+}
+
+type KeyInformation struct {
+	Algorithm string `json:"algorithm"`
+	KeyLength int    `json:"key_length,omitempty"`
+}
+
+type KeyRequest struct {
+	KeyInformation   *KeyInformation `json:"key_information"`
+	TransferPolicyID string          `json:"transfer_policy_id,omitempty"`
+}
+
+type KeyResponse struct {
+	ID               string          `json:"id"`
+	KeyInformation   *KeyInformation `json:"key_information,omitempty"`
+	TransferPolicyID string          `json:"transfer_policy_id,omitempty"`
+	TransferLink     string          `json:"transfer_link,omitempty"`
+}
+
+type WrappedKeyResponse struct {
+	WrappedKey []byte `json:"wrapped_key"`
+}
+
+// GetToken sends a POST request to KBS to retrieve a bearer token for the admin API
+func (kc *kbsAdminClient) GetToken(ctx context.Context, username, password string) (string, error) {
+
+	newRequest := func() (*http.Request, error) {
+		reqBytes, err := json.Marshal(&TokenRequest{Username: username, Password: password})
+		if err != nil {
+			return nil, err
+		}
+
+		return http.NewRequestWithContext(ctx, http.MethodPost, kc.BaseURL.JoinPath("token").String(), bytes.NewReader(reqBytes))
+	}
+
+	var headers = map[string]string{
+		HTTPHeaderKeyContentType: HTTPHeaderValueApplicationJson,
+		HTTPHeaderKeyAccept:      HTTPHeaderValueApplicationJwt,
+	}
+
+	var token string
+	processResponse := func(resp *http.Response) error {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		token = strings.TrimSpace(string(body))
+		return nil
+	}
+
+	if err := kc.requestAndProcessResponse(newRequest, nil, headers, processResponse); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// CreateKeyTransferPolicy sends a POST request to KBS to create a key transfer policy
+func (kc *kbsAdminClient) CreateKeyTransferPolicy(ctx context.Context, policy *KeyTransferPolicy) (*KeyTransferPolicy, error) {
+
+	var created KeyTransferPolicy
+	if err := kc.postJSON(ctx, kc.BaseURL.JoinPath("key-transfer-policies"), policy, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// CreateKey sends a POST request to KBS to create a key
+func (kc *kbsAdminClient) CreateKey(ctx context.Context, request *KeyRequest) (*KeyResponse, error) {
+
+	var created KeyResponse
+	if err := kc.postJSON(ctx, kc.BaseURL.JoinPath("keys"), request, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// GetWrappedKey sends a POST request to KBS to retrieve the key wrapped with the given PEM encoded public key
+func (kc *kbsAdminClient) GetWrappedKey(ctx context.Context, keyID string, publicKey []byte) ([]byte, error) {
+
+	newRequest := func() (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodPost, kc.BaseURL.JoinPath("keys", keyID).String(), bytes.NewReader(publicKey))
+	}
+
+	var headers = map[string]string{
+		HTTPHeaderKeyContentType:   HTTPHeaderValueApplicationXPEM,
+		HTTPHeaderKeyAccept:        HTTPHeaderValueApplicationJson,
+		HTTPHeaderKeyAuthorization: "Bearer " + kc.Token,
+	}
+
+	var response WrappedKeyResponse
+	processResponse := func(resp *http.Response) error {
+		return json.NewDecoder(resp.Body).Decode(&response)
+	}
+
+	if err := kc.requestAndProcessResponse(newRequest, nil, headers, processResponse); err != nil {
+		return nil, err
+	}
+
+	return response.WrappedKey, nil
+}
+
+func (kc *kbsAdminClient) postJSON(ctx context.Context, url *url.URL, request, response interface{}) error {
+
+	newRequest := func() (*http.Request, error) {
+		reqBytes, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+
+		return http.NewRequestWithContext(ctx, http.MethodPost, url.String(), bytes.NewReader(reqBytes))
+	}
+
+	var headers = map[string]string{
+		HTTPHeaderKeyContentType:   HTTPHeaderValueApplicationJson,
+		HTTPHeaderKeyAccept:        HTTPHeaderValueApplicationJson,
+		HTTPHeaderKeyAuthorization: "Bearer " + kc.Token,
+	}
+
+	processResponse := func(resp *http.Response) error {
+		return json.NewDecoder(resp.Body).Decode(response)
+	}
+
+	return kc.requestAndProcessResponse(newRequest, nil, headers, processResponse)
+}
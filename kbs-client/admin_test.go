@@ -0,0 +1,163 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	client "github.com/intel/kbs/v1/client"
+	"github.com/intel/kbs/v1/client/kbstest"
+)
+
+func TestAdminClientCreateAndGetKey(t *testing.T) {
+	ctx := context.Background()
+	kbs := kbstest.NewServer(t)
+
+	token, err := client.NewKBSAdminClient(kbs.Client(), kbs.BaseURL(), "").GetToken(ctx, kbstest.Username, kbstest.Password)
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if token != kbstest.Token {
+		t.Fatalf("GetToken returned %q, want %q", token, kbstest.Token)
+	}
+
+	kc := client.NewKBSAdminClient(kbs.Client(), kbs.BaseURL(), token)
+	policy, err := kc.CreateKeyTransferPolicy(ctx, &client.KeyTransferPolicy{
+		AttestationType: client.AttestationTypeTDX,
+		TDX:             &client.TdxPolicy{PolicyIds: []string{"p"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateKeyTransferPolicy: %v", err)
+	}
+	if policy.ID != kbstest.PolicyID || policy.TDX == nil || len(policy.TDX.PolicyIds) != 1 {
+		t.Fatalf("CreateKeyTransferPolicy returned %+v", policy)
+	}
+
+	key, err := kc.CreateKey(ctx, &client.KeyRequest{
+		KeyInformation:   &client.KeyInformation{Algorithm: client.KeyAlgorithmAES, KeyLength: 256},
+		TransferPolicyID: policy.ID,
+	})
+	if err != nil {
+		t.Fatalf("CreateKey: %v", err)
+	}
+	if key.ID == "" || key.TransferPolicyID != policy.ID || key.KeyInformation.KeyLength != 256 {
+		t.Fatalf("CreateKey returned %+v", key)
+	}
+
+	pri, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&pri.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrapped, err := kc.GetWrappedKey(ctx, key.ID, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	if err != nil {
+		t.Fatalf("GetWrappedKey: %v", err)
+	}
+	unwrapped, err := rsa.DecryptOAEP(sha512.New384(), rand.Reader, pri, wrapped, nil)
+	if err != nil {
+		t.Fatalf("unwrap: %v", err)
+	}
+	if !bytes.Equal(unwrapped, kbs.Key(key.ID)) {
+		t.Fatal("GetWrappedKey returned a different key")
+	}
+}
+
+func TestAdminClientErrors(t *testing.T) {
+	ctx := context.Background()
+	kbs := kbstest.NewServer(t)
+	kbs.AddKey("key-1", make([]byte, 32))
+	kc := client.NewKBSAdminClient(kbs.Client(), kbs.BaseURL(), kbstest.Token)
+	unauthorized := client.NewKBSAdminClient(kbs.Client(), kbs.BaseURL(), "wrong")
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{not json"))
+	}))
+	defer broken.Close()
+	brokenURL, _ := url.Parse(broken.URL)
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"wrong credentials", func() error {
+			_, err := kc.GetToken(ctx, kbstest.Username, "wrong")
+			return err
+		}},
+		{"policy without token", func() error {
+			_, err := unauthorized.CreateKeyTransferPolicy(ctx, &client.KeyTransferPolicy{AttestationType: client.AttestationTypeTDX})
+			return err
+		}},
+		{"invalid policy", func() error {
+			_, err := kc.CreateKeyTransferPolicy(ctx, &client.KeyTransferPolicy{AttestationType: "SGX"})
+			return err
+		}},
+		{"key without token", func() error {
+			_, err := unauthorized.CreateKey(ctx, &client.KeyRequest{KeyInformation: &client.KeyInformation{Algorithm: client.KeyAlgorithmAES}, TransferPolicyID: kbstest.PolicyID})
+			return err
+		}},
+		{"key without policy", func() error {
+			_, err := kc.CreateKey(ctx, &client.KeyRequest{KeyInformation: &client.KeyInformation{Algorithm: client.KeyAlgorithmAES}})
+			return err
+		}},
+		{"unknown key", func() error {
+			_, err := kc.GetWrappedKey(ctx, "key-2", []byte("public key"))
+			return err
+		}},
+		{"invalid public key", func() error {
+			_, err := kc.GetWrappedKey(ctx, "key-1", []byte("public key"))
+			return err
+		}},
+		{"wrapped key without token", func() error {
+			_, err := unauthorized.GetWrappedKey(ctx, "key-1", []byte("public key"))
+			return err
+		}},
+		{"invalid response", func() error {
+			_, err := client.NewKBSAdminClient(broken.Client(), brokenURL, kbstest.Token).CreateKey(ctx, &client.KeyRequest{})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestAdminClientCanceled(t *testing.T) {
+	kbs := kbstest.NewServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.NewKBSAdminClient(kbs.Client(), kbs.BaseURL(), kbstest.Token).CreateKey(ctx, &client.KeyRequest{})
+	if err == nil {
+		t.Fatal("expected an error for a canceled context")
+	}
+}
+
+func TestAdminClientUnreachable(t *testing.T) {
+	kbs := kbstest.NewServer(t)
+	kbs.Close()
+
+	_, err := client.NewKBSAdminClient(kbs.Client(), kbs.BaseURL(), kbstest.Token).CreateKey(context.Background(), &client.KeyRequest{})
+	if err == nil {
+		t.Fatal("expected an error for a KBS that is not reachable")
+	}
+}
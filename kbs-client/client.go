@@ -5,6 +5,7 @@
 package client
 
 import (
+	"context"
 	"net/http"
 	"net/url"
 
@@ -12,9 +13,11 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// KBSClient transfers keys from KBS. The context is attached to the outgoing
+// requests, so that cancellation, tracing and request IDs reach the transport.
 type KBSClient interface {
-	TransferKey() ([]byte, string, error)
-	TransferKeyWithEvidence(*KeyTransferRequest, string) ([]byte, error)
+	TransferKey(context.Context) ([]byte, string, error)
+	TransferKeyWithEvidence(context.Context, *KeyTransferRequest, string) ([]byte, error)
 }
 
 type kbsClient struct {
@@ -74,7 +77,8 @@ func (kc *kbsClient) requestAndProcessResponse(
 		}()
 	}
 
-	if resp.StatusCode != http.StatusOK || resp.ContentLength == 0 {
+	// the admin API answers resource creation with 201
+	if (resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated) || resp.ContentLength == 0 {
 		return errors.Errorf("Invalid response: StatusCode = %d, ContentLength = %d", resp.StatusCode, resp.ContentLength)
 	}
 
@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+// Package kbstest provides a stand-in for the KBS admin API to test clients
+// against.
+package kbstest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	client "github.com/intel/kbs/v1/client"
+)
+
+const (
+	// Username and Password are the credentials GetToken accepts
+	Username = "admin"
+	Password = "secret"
+	// Token is the bearer token the admin API accepts
+	Token = "test-token"
+	// PolicyID is the ID of every created key transfer policy
+	PolicyID = "policy-1"
+)
+
+// Server is a TLS stand-in for the KBS admin API rooted at /kbs/v1. Like KBS
+// it wraps its keys with RSA-OAEP SHA-384 under the posted public key.
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	keys       map[string][]byte
+	created    int
+	failCreate bool
+}
+
+// NewServer starts a stand-in KBS that is closed when the test ends
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{keys: map[string][]byte{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /kbs/v1/token", s.token)
+	mux.HandleFunc("POST /kbs/v1/key-transfer-policies", s.authorized(s.createPolicy))
+	mux.HandleFunc("POST /kbs/v1/keys", s.authorized(s.createKey))
+	mux.HandleFunc("POST /kbs/v1/keys/{id}", s.authorized(s.wrappedKey))
+
+	s.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// BaseURL returns the URL of the admin API
+func (s *Server) BaseURL() *url.URL {
+	baseURL, _ := url.Parse(s.URL)
+	return baseURL.JoinPath("kbs", "v1")
+}
+
+// AddKey stores key under id
+func (s *Server) AddKey(id string, key []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[id] = key
+}
+
+// Key returns the key stored under id, nil if there is none
+func (s *Server) Key(id string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.keys[id]
+}
+
+// FailCreate makes key creation fail with 500 while fail is set
+func (s *Server) FailCreate(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failCreate = fail
+}
+
+func (s *Server) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(client.HTTPHeaderKeyAuthorization) != "Bearer "+Token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	var req client.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Username != Username || req.Password != Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Write([]byte(Token + "\n"))
+}
+
+func (s *Server) createPolicy(w http.ResponseWriter, r *http.Request) {
+	var policy client.KeyTransferPolicy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil || policy.AttestationType != client.AttestationTypeTDX {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	policy.ID = PolicyID
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(policy)
+}
+
+func (s *Server) createKey(w http.ResponseWriter, r *http.Request) {
+	var req client.KeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.KeyInformation == nil || req.TransferPolicyID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	keyLength := req.KeyInformation.KeyLength
+	if keyLength == 0 {
+		keyLength = 256
+	}
+	key := make([]byte, keyLength/8)
+	if _, err := rand.Read(key); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	if s.failCreate {
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	s.created++
+	id := fmt.Sprintf("key-%d", s.created)
+	s.keys[id] = key
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(client.KeyResponse{
+		ID:               id,
+		KeyInformation:   req.KeyInformation,
+		TransferPolicyID: req.TransferPolicyID,
+		TransferLink:     s.BaseURL().JoinPath("keys", id, "transfer").String(),
+	})
+}
+
+func (s *Server) wrappedKey(w http.ResponseWriter, r *http.Request) {
+	key := s.Key(r.PathValue("id"))
+	if key == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Header.Get(client.HTTPHeaderKeyContentType) != client.HTTPHeaderValueApplicationXPEM {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	block, _ := pem.Decode(body)
+	if block == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	wrapped, err := rsa.EncryptOAEP(sha512.New384(), rand.Reader, rsaPub, key, nil)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(client.WrappedKeyResponse{WrappedKey: wrapped})
+}
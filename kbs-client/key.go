@@ -6,6 +6,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
@@ -35,10 +36,10 @@ type KeyTransferResponse struct {
 }
 
 // TransferKey sends a POST request to Relying Party to retrieve the challenge data to be used as userdata for quote generation
-func (kc *kbsClient) TransferKey() ([]byte, string, error) {
+func (kc *kbsClient) TransferKey(ctx context.Context) ([]byte, string, error) {
 
 	newRequest := func() (*http.Request, error) {
-		return http.NewRequest(http.MethodPost, kc.BaseURL.String(), nil)
+		return http.NewRequestWithContext(ctx, http.MethodPost, kc.BaseURL.String(), nil)
 	}
 
 	var queryParams map[string]string = nil
@@ -67,7 +68,7 @@ func (kc *kbsClient) TransferKey() ([]byte, string, error) {
 }
 
 // TransferKeyWithEvidence sends a POST request to Relying Party to retrieve the actual resource
-func (kc *kbsClient) TransferKeyWithEvidence(request *KeyTransferRequest, attestationType string) ([]byte, error) {
+func (kc *kbsClient) TransferKeyWithEvidence(ctx context.Context, request *KeyTransferRequest, attestationType string) ([]byte, error) {
 
 	newRequest := func() (*http.Request, error) {
 		reqBytes, err := json.Marshal(request)
@@ -75,7 +76,7 @@ func (kc *kbsClient) TransferKeyWithEvidence(request *KeyTransferRequest, attest
 			return nil, err
 		}
 
-		return http.NewRequest(http.MethodPost, kc.BaseURL.String(), bytes.NewReader(reqBytes))
+		return http.NewRequestWithContext(ctx, http.MethodPost, kc.BaseURL.String(), bytes.NewReader(reqBytes))
 	}
 
 	var queryParams map[string]string = nil
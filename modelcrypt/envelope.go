@@ -0,0 +1,258 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+// Package modelcrypt implements the on-disk format of encrypted models shared
+// by the encryptor and the TDX workload.
+package modelcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	FormatVersion1 = 1
+
+	CipherSuiteAES128GCM = "AES-128-GCM"
+	CipherSuiteAES256GCM = "AES-256-GCM"
+
+	// maxHeaderSize bounds the metadata read from untrusted input
+	maxHeaderSize = 64 * 1024
+)
+
+// ErrAuthentication is the cause of errors reporting that an encrypted model
+// or its header failed authentication, because it was modified, truncated or
+// the key is wrong
+var ErrAuthentication = errors.New("model failed authentication")
+
+// Magic identifies an enveloped model. Legacy models start directly with a
+// random GCM nonce.
+var Magic = []byte("TAMF")
+
+// prefixSize is the size of magic || version || header length
+var prefixSize = len(Magic) + 1 + 4
+
+// Header is the plaintext metadata stored in front of an enveloped model. The
+// serialized header is bound to the ciphertext as GCM additional data.
+type Header struct {
+	Version         uint8  `json:"-"`
+	CipherSuite     string `json:"cipher_suite"`
+	KeyID           string `json:"key_id,omitempty"`
+	KeyTransferURL  string `json:"key_transfer_url,omitempty"`
+	ModelName       string `json:"model_name,omitempty"`
+	ModelVersion    string `json:"model_version,omitempty"`
+	PlaintextSHA384 string `json:"plaintext_sha384,omitempty"`
+	ChunkSize       int    `json:"chunk_size,omitempty"`
+	// Recipients hold the wrapped content key of models that can be
+	// decrypted with more than one KBS key. KeyID and KeyTransferURL are
+	// then left empty.
+	Recipients []Recipient `json:"recipients,omitempty"`
+}
+
+// IsEnvelope reports whether data starts with the envelope magic
+func IsEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, Magic)
+}
+
+// Seal encrypts the model with AES-GCM and prepends the header. The cipher
+// suite and plaintext digest of the header are filled in from key and model.
+// For headers with recipients key is the content encryption key.
+func Seal(key []byte, header Header, model []byte) ([]byte, error) {
+	suite, err := cipherSuiteForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha512.Sum384(model)
+	header.Version = FormatVersion1
+	header.CipherSuite = suite
+	header.PlaintextSHA384 = hex.EncodeToString(digest[:])
+
+	hdr, err := marshalHeader(&header)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, errors.Wrap(err, "Error creating random IV value")
+	}
+
+	out := append(hdr, iv...)
+	return gcm.Seal(out, iv, model, hdr), nil
+}
+
+// Open decrypts an encrypted model held in memory in any of the supported
+// formats. The returned header is nil for legacy models.
+func Open(key []byte, data []byte) (*Header, []byte, error) {
+	if !IsEnvelope(data) {
+		model, err := OpenLegacy(key, data)
+		return nil, model, err
+	}
+
+	header, offset, err := ParseHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if header.Version == FormatVersion2 {
+		sr, err := NewReader(bytes.NewReader(data), key)
+		if err != nil {
+			return nil, nil, err
+		}
+		model, err := io.ReadAll(sr)
+		sr.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		return header, model, nil
+	}
+
+	key, cleanup, err := header.contentKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cleanup()
+
+	suite, err := cipherSuiteForKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if suite != header.CipherSuite {
+		return nil, nil, errors.Errorf("Key does not match cipher suite %s", header.CipherSuite)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body := data[offset:]
+	if len(body) < gcm.NonceSize() {
+		return nil, nil, errors.New("Invalid cipher text")
+	}
+	iv, cipherText := body[:gcm.NonceSize()], body[gcm.NonceSize():]
+	model, err := gcm.Open(nil, iv, cipherText, data[:offset])
+	if err != nil {
+		return nil, nil, errors.Wrap(ErrAuthentication, "Error decrypting data")
+	}
+
+	if err = header.verifyDigest(model); err != nil {
+		return nil, nil, err
+	}
+	return header, model, nil
+}
+
+// OpenLegacy decrypts a model in the original iv || ciphertext || tag format
+func OpenLegacy(key []byte, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := gcm.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("Invalid cipher text")
+	}
+
+	model, err := gcm.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, errors.Wrap(ErrAuthentication, "Error decrypting data")
+	}
+	return model, nil
+}
+
+// ParseHeader reads the header of an enveloped model without decrypting it.
+// It returns the header and the offset at which the encrypted payload starts.
+func ParseHeader(data []byte) (*Header, int, error) {
+	if !IsEnvelope(data) || len(data) < prefixSize {
+		return nil, 0, errors.New("Not an enveloped model")
+	}
+
+	version := data[len(Magic)]
+	if version != FormatVersion1 && version != FormatVersion2 {
+		return nil, 0, errors.Errorf("Unsupported model format version %d", version)
+	}
+
+	hdrLen := binary.BigEndian.Uint32(data[len(Magic)+1 : prefixSize])
+	if hdrLen > maxHeaderSize || int(hdrLen) > len(data)-prefixSize {
+		return nil, 0, errors.New("Invalid model header length")
+	}
+
+	var header Header
+	dec := json.NewDecoder(bytes.NewReader(data[prefixSize : prefixSize+int(hdrLen)]))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&header); err != nil {
+		return nil, 0, errors.Wrap(err, "Error decoding model header")
+	}
+	header.Version = version
+
+	return &header, prefixSize + int(hdrLen), nil
+}
+
+func (h *Header) verifyDigest(model []byte) error {
+	expected, err := hex.DecodeString(h.PlaintextSHA384)
+	if err != nil {
+		return errors.Wrap(err, "Invalid model digest in header")
+	}
+	digest := sha512.Sum384(model)
+	if subtle.ConstantTimeCompare(expected, digest[:]) != 1 {
+		return errors.Wrap(ErrAuthentication, "Model digest does not match header")
+	}
+	return nil
+}
+
+func marshalHeader(header *Header) ([]byte, error) {
+	js, err := json.Marshal(header)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error encoding model header")
+	}
+	if len(js) > maxHeaderSize {
+		return nil, errors.New("Model header is too large")
+	}
+
+	hdr := make([]byte, prefixSize, prefixSize+len(js))
+	copy(hdr, Magic)
+	hdr[len(Magic)] = header.Version
+	binary.BigEndian.PutUint32(hdr[len(Magic)+1:], uint32(len(js)))
+	return append(hdr, js...), nil
+}
+
+func cipherSuiteForKey(key []byte) (string, error) {
+	switch len(key) {
+	case 16:
+		return CipherSuiteAES128GCM, nil
+	case 32:
+		return CipherSuiteAES256GCM, nil
+	}
+	return "", errors.Errorf("Unsupported key length %d", len(key))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error initializing cipher")
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error creating a cipher block")
+	}
+	return gcm, nil
+}
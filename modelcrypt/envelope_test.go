@@ -0,0 +1,171 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package modelcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"reflect"
+	"testing"
+)
+
+func testKey(t *testing.T, size int) []byte {
+	t.Helper()
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSealOpen(t *testing.T) {
+	model := []byte("a model that is not really a model")
+
+	for _, size := range []int{16, 32} {
+		key := testKey(t, size)
+		sealed, err := Seal(key, Header{KeyID: "key-1", ModelName: "diabetes", ModelVersion: "2"}, model)
+		if err != nil {
+			t.Fatalf("Seal: %v", err)
+		}
+		if !IsEnvelope(sealed) {
+			t.Fatal("sealed model does not start with the envelope magic")
+		}
+
+		header, opened, err := Open(key, sealed)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if !bytes.Equal(opened, model) {
+			t.Fatalf("Open returned %q, want %q", opened, model)
+		}
+		if header.Version != FormatVersion1 || header.KeyID != "key-1" || header.ModelName != "diabetes" || header.ModelVersion != "2" {
+			t.Fatalf("unexpected header %+v", header)
+		}
+		if suite, _ := cipherSuiteForKey(key); header.CipherSuite != suite {
+			t.Fatalf("cipher suite %s, want %s", header.CipherSuite, suite)
+		}
+
+		parsed, _, err := ParseHeader(sealed)
+		if err != nil {
+			t.Fatalf("ParseHeader: %v", err)
+		}
+		if !reflect.DeepEqual(parsed, header) {
+			t.Fatalf("ParseHeader returned %+v, want %+v", parsed, header)
+		}
+	}
+}
+
+func TestOpenRejectsTampering(t *testing.T) {
+	key := testKey(t, 32)
+	sealed, err := Seal(key, Header{ModelName: "diabetes"}, []byte("model"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, offset, err := ParseHeader(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    []byte
+		tamper func([]byte) []byte
+	}{
+		{
+			// the header is only authenticated as additional data, so a
+			// change that still decodes must fail the GCM tag check
+			name: "header",
+			key:  key,
+			tamper: func(data []byte) []byte {
+				return bytes.Replace(data, []byte(`"diabetes"`), []byte(`"Diabetes"`), 1)
+			},
+		},
+		{
+			name: "ciphertext",
+			key:  key,
+			tamper: func(data []byte) []byte {
+				data[len(data)-20] ^= 1
+				return data
+			},
+		},
+		{
+			name: "tag",
+			key:  key,
+			tamper: func(data []byte) []byte {
+				data[len(data)-1] ^= 1
+				return data
+			},
+		},
+		{
+			name:   "truncated",
+			key:    key,
+			tamper: func(data []byte) []byte { return data[:offset+4] },
+		},
+		{
+			name: "unsupported version",
+			key:  key,
+			tamper: func(data []byte) []byte {
+				data[len(Magic)] = 2
+				return data
+			},
+		},
+		{
+			name:   "wrong key",
+			key:    testKey(t, 32),
+			tamper: func(data []byte) []byte { return data },
+		},
+		{
+			name:   "key of another cipher suite",
+			key:    testKey(t, 16),
+			tamper: func(data []byte) []byte { return data },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.tamper(append([]byte(nil), sealed...))
+			if _, _, err := Open(tt.key, data); err == nil {
+				t.Fatal("Open accepted a tampered model")
+			}
+		})
+	}
+}
+
+func TestOpenLegacy(t *testing.T) {
+	key := testKey(t, 32)
+	model := []byte("legacy model")
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := testKey(t, gcm.NonceSize())
+	legacy := gcm.Seal(append([]byte(nil), iv...), iv, model, nil)
+
+	header, opened, err := Open(key, legacy)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if header != nil {
+		t.Fatalf("Open returned header %+v for a legacy model", header)
+	}
+	if !bytes.Equal(opened, model) {
+		t.Fatalf("Open returned %q, want %q", opened, model)
+	}
+
+	legacy[len(legacy)-1] ^= 1
+	if _, _, err := Open(key, legacy); err == nil {
+		t.Fatal("Open accepted a tampered legacy model")
+	}
+	if _, err := OpenLegacy(key, iv[:4]); err == nil {
+		t.Fatal("OpenLegacy accepted a short input")
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package modelcrypt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/youmark/pkcs8"
+)
+
+// OAEP hashes KBS wraps keys with. Keys fetched through the KBS admin API are
+// wrapped with SHA-384, the SWK returned by a key transfer with SHA-256.
+const (
+	AdminKeyWrapHash    = crypto.SHA384
+	TransferKeyWrapHash = crypto.SHA256
+)
+
+var oaepHashes = map[string]crypto.Hash{
+	"sha256": crypto.SHA256,
+	"sha384": crypto.SHA384,
+	"sha512": crypto.SHA512,
+}
+
+// ParseOAEPHash returns the hash for one of the names sha256, sha384 or sha512
+func ParseOAEPHash(name string) (crypto.Hash, error) {
+	hash, ok := oaepHashes[strings.ToLower(strings.ReplaceAll(name, "-", ""))]
+	if !ok {
+		return 0, errors.Errorf("Unsupported OAEP hash %q, must be sha256, sha384 or sha512", name)
+	}
+	return hash, nil
+}
+
+// PassphraseFunc returns the passphrase of an encrypted private key. It is
+// only called for encrypted keys.
+type PassphraseFunc func() ([]byte, error)
+
+// ParsePrivateKey parses a PEM encoded RSA private key in PKCS#1 or PKCS#8
+// form. Encrypted PKCS#8 keys are decrypted with the passphrase from
+// passphrase. Legacy OpenSSL encrypted PKCS#1 keys are rejected, their
+// encryption is not authenticated.
+func ParsePrivateKey(data []byte, passphrase PassphraseFunc) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("private key not found")
+	}
+	defer zeroizeByteArray(block.Bytes)
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		if _, encrypted := block.Headers["Proc-Type"]; encrypted {
+			return nil, errors.New("Legacy encrypted PKCS#1 keys are not supported, convert the key with \"openssl pkcs8 -topk8 -v2 aes-256-cbc\"")
+		}
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "ENCRYPTED PRIVATE KEY":
+		pass, perr := getPassphrase(passphrase)
+		if perr != nil {
+			return nil, perr
+		}
+		defer zeroizeByteArray(pass)
+		key, err = pkcs8.ParsePKCS8PrivateKey(block.Bytes, pass)
+	default:
+		return nil, errors.Errorf("Unsupported private key type %q, must be an RSA key in PKCS#1 or PKCS#8 form", block.Type)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Error decoding private key")
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("Unsupported private key type %T, must be an RSA key", key)
+	}
+	return rsaKey, nil
+}
+
+func getPassphrase(passphrase PassphraseFunc) ([]byte, error) {
+	if passphrase == nil {
+		return nil, errors.New("Private key is encrypted but no passphrase was provided")
+	}
+	pass, err := passphrase()
+	if err != nil {
+		return nil, errors.Wrap(err, "Error reading private key passphrase")
+	}
+	return pass, nil
+}
+
+// UnwrapKey decrypts a key that KBS wrapped with RSA-OAEP using hash
+func UnwrapKey(pri *rsa.PrivateKey, wrappedKey []byte, hash crypto.Hash) ([]byte, error) {
+	if !hash.Available() {
+		return nil, errors.Errorf("Unsupported OAEP hash %s", hash)
+	}
+
+	key, err := rsa.DecryptOAEP(hash.New(), rand.Reader, pri, wrappedKey, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error while decrypting the key")
+	}
+	return key, nil
+}
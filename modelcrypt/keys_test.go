@@ -0,0 +1,182 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package modelcrypt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/youmark/pkcs8"
+)
+
+func TestParsePrivateKey(t *testing.T) {
+	pri, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(pri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encryptedDER, err := pkcs8.MarshalPrivateKey(pri, []byte("passphrase"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passphrase := func(pass string) PassphraseFunc {
+		return func() ([]byte, error) { return []byte(pass), nil }
+	}
+	encode := func(block *pem.Block) []byte {
+		return pem.EncodeToMemory(block)
+	}
+
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase PassphraseFunc
+		wantErr    bool
+	}{
+		{
+			name: "pkcs1",
+			data: encode(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(pri)}),
+		},
+		{
+			name: "pkcs8",
+			data: encode(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER}),
+		},
+		{
+			name:       "encrypted pkcs8",
+			data:       encode(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedDER}),
+			passphrase: passphrase("passphrase"),
+		},
+		{
+			name:       "encrypted pkcs8 with wrong passphrase",
+			data:       encode(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedDER}),
+			passphrase: passphrase("wrong"),
+			wantErr:    true,
+		},
+		{
+			name:    "encrypted pkcs8 without passphrase",
+			data:    encode(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedDER}),
+			wantErr: true,
+		},
+		{
+			name:       "passphrase error",
+			data:       encode(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptedDER}),
+			passphrase: func() ([]byte, error) { return nil, errors.New("no terminal") },
+			wantErr:    true,
+		},
+		{
+			name: "legacy encrypted pkcs1",
+			data: encode(&pem.Block{
+				Type:    "RSA PRIVATE KEY",
+				Headers: map[string]string{"Proc-Type": "4,ENCRYPTED", "DEK-Info": "AES-256-CBC,00112233445566778899AABBCCDDEEFF"},
+				Bytes:   x509.MarshalPKCS1PrivateKey(pri),
+			}),
+			passphrase: passphrase("passphrase"),
+			wantErr:    true,
+		},
+		{
+			name:    "not an rsa key",
+			data:    encode(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER}),
+			wantErr: true,
+		},
+		{
+			name:    "unsupported block",
+			data:    encode(&pem.Block{Type: "CERTIFICATE", Bytes: pkcs8DER}),
+			wantErr: true,
+		},
+		{
+			name:    "not pem",
+			data:    []byte("not a key"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParsePrivateKey(tt.data, tt.passphrase)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePrivateKey: %v", err)
+			}
+			if !key.Equal(pri) {
+				t.Fatal("ParsePrivateKey returned a different key")
+			}
+		})
+	}
+}
+
+func TestParseOAEPHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    crypto.Hash
+		wantErr bool
+	}{
+		{name: "sha256", want: crypto.SHA256},
+		{name: "SHA-384", want: crypto.SHA384},
+		{name: "sha512", want: crypto.SHA512},
+		{name: "sha1", wantErr: true},
+		{name: "md5", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := ParseOAEPHash(tt.name)
+			if (err != nil) != tt.wantErr || hash != tt.want {
+				t.Fatalf("ParseOAEPHash(%q) = %v, %v", tt.name, hash, err)
+			}
+		})
+	}
+}
+
+func TestUnwrapKey(t *testing.T) {
+	pri, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := bytes.Repeat([]byte{5}, 32)
+
+	wrapped, err := rsa.EncryptOAEP(AdminKeyWrapHash.New(), rand.Reader, &pri.PublicKey, key, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := UnwrapKey(pri, wrapped, AdminKeyWrapHash)
+	if err != nil {
+		t.Fatalf("UnwrapKey: %v", err)
+	}
+	if !bytes.Equal(unwrapped, key) {
+		t.Fatal("UnwrapKey returned a different key")
+	}
+
+	if _, err = UnwrapKey(pri, wrapped, TransferKeyWrapHash); err == nil {
+		t.Fatal("UnwrapKey accepted the wrong OAEP hash")
+	}
+	if _, err = UnwrapKey(pri, wrapped, crypto.Hash(0)); err == nil {
+		t.Fatal("UnwrapKey accepted an unavailable hash")
+	}
+}
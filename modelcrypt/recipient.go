@@ -0,0 +1,102 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package modelcrypt
+
+import (
+	"crypto/rand"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// A model with recipients is encrypted with a random content encryption key
+// (CEK). The CEK is wrapped with AES-GCM under each recipient's KBS key and
+// stored in the header, so that any one of the KBS keys decrypts the model.
+// The header, including all recipients, stays bound to the payload as
+// additional data.
+const contentKeySize = 32
+
+// recipientAD separates wrapped content keys from other uses of a KBS key
+var recipientAD = []byte("TAMF content key")
+
+// Recipient is the content encryption key wrapped with one KBS key
+type Recipient struct {
+	KeyID          string `json:"key_id,omitempty"`
+	KeyTransferURL string `json:"key_transfer_url,omitempty"`
+	WrappedKey     []byte `json:"wrapped_key"`
+}
+
+// NewContentKey returns a random AES-256 content encryption key
+func NewContentKey() ([]byte, error) {
+	cek := make([]byte, contentKeySize)
+	if _, err := io.ReadFull(rand.Reader, cek); err != nil {
+		return nil, errors.Wrap(err, "Error creating content encryption key")
+	}
+	return cek, nil
+}
+
+// WrapContentKey wraps the content encryption key with the KBS key kek for the
+// recipient identified by keyID and keyTransferURL
+func WrapContentKey(kek, cek []byte, keyID, keyTransferURL string) (Recipient, error) {
+	if _, err := cipherSuiteForKey(kek); err != nil {
+		return Recipient{}, err
+	}
+
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return Recipient{}, err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, iv); err != nil {
+		return Recipient{}, errors.Wrap(err, "Error creating random IV value")
+	}
+
+	return Recipient{
+		KeyID:          keyID,
+		KeyTransferURL: keyTransferURL,
+		WrappedKey:     gcm.Seal(iv, iv, cek, recipientAD),
+	}, nil
+}
+
+// ContentKey returns the key the payload is encrypted with. For models
+// without recipients that is key itself. Otherwise each recipient is tried in
+// turn and the content key of the first one key unwraps is returned together
+// with that recipient.
+func (h *Header) ContentKey(key []byte) ([]byte, *Recipient, error) {
+	if len(h.Recipients) == 0 {
+		return key, nil, nil
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range h.Recipients {
+		wrapped := h.Recipients[i].WrappedKey
+		if len(wrapped) < gcm.NonceSize() {
+			continue
+		}
+		cek, err := gcm.Open(nil, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], recipientAD)
+		if err == nil {
+			return cek, &h.Recipients[i], nil
+		}
+	}
+	return nil, nil, errors.Wrap(ErrAuthentication, "Key does not unwrap the content key of any recipient")
+}
+
+// contentKey is ContentKey for the decryption paths. The returned cleanup
+// zeroizes the content key once it is no longer needed.
+func (h *Header) contentKey(key []byte) ([]byte, func(), error) {
+	cek, recipient, err := h.ContentKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if recipient == nil {
+		return cek, func() {}, nil
+	}
+	return cek, func() { zeroizeByteArray(cek) }, nil
+}
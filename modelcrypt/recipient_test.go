@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package modelcrypt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRecipients(t *testing.T) {
+	model := bytes.Repeat([]byte("model "), 10)
+	keks := [][]byte{testKey(t, 32), testKey(t, 16)}
+
+	cek, err := NewContentKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := Header{ModelName: "diabetes"}
+	for i, kek := range keks {
+		recipient, err := WrapContentKey(kek, cek, []string{"key-1", "key-2"}[i], "")
+		if err != nil {
+			t.Fatalf("WrapContentKey: %v", err)
+		}
+		header.Recipients = append(header.Recipients, recipient)
+	}
+
+	sealed, err := Seal(cek, header, model)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	var stream bytes.Buffer
+	w, err := NewWriter(&stream, cek, Header{ChunkSize: testChunkSize, Recipients: header.Recipients})
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	w.Write(model)
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for name, data := range map[string][]byte{"envelope": sealed, "stream": stream.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			for i, kek := range keks {
+				opened, err := readAll(kek, data)
+				if err != nil {
+					t.Fatalf("recipient %d: %v", i, err)
+				}
+				if !bytes.Equal(opened, model) {
+					t.Fatalf("recipient %d decrypted a different model", i)
+				}
+
+				parsed, _, err := ParseHeader(data)
+				if err != nil {
+					t.Fatal(err)
+				}
+				unwrapped, recipient, err := parsed.ContentKey(kek)
+				if err != nil || !bytes.Equal(unwrapped, cek) || recipient.KeyID != header.Recipients[i].KeyID {
+					t.Fatalf("ContentKey for recipient %d returned %v, %+v", i, err, recipient)
+				}
+			}
+
+			// keys of either size that are not a recipient fail
+			for _, key := range [][]byte{testKey(t, 32), testKey(t, 16)} {
+				_, err := readAll(key, data)
+				if errors.Cause(err) != ErrAuthentication {
+					t.Fatalf("wrong key returned %v, want %v", err, ErrAuthentication)
+				}
+			}
+		})
+	}
+
+	// the recipients are part of the authenticated header, a replaced
+	// wrapped key fails even for the other recipient
+	other, err := WrapContentKey(keks[0], testKey(t, 32), "key-1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, offset, err := ParseHeader(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.Recipients[0] = other
+	hdr, err := marshalHeader(parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := append(hdr, sealed[offset:]...)
+	if _, err := readAll(keks[1], tampered); err == nil {
+		t.Fatal("model with a replaced recipient was decrypted")
+	}
+}
@@ -0,0 +1,419 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package modelcrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// The chunked format (version 2) follows the STREAM construction: the model is
+// split into fixed size chunks that are sealed individually with AES-GCM under
+// the nonce
+//
+//	nonce prefix (7 bytes) || chunk counter (4 bytes, big endian) || last chunk flag (1 byte)
+//
+// so that truncated, reordered or duplicated chunks fail authentication. The
+// serialized header is the additional data of every chunk. The random nonce
+// prefix is written right after the header.
+const (
+	FormatVersion2 = 2
+
+	DefaultChunkSize = 64 * 1024
+	maxChunkSize     = 16 * 1024 * 1024
+
+	noncePrefixSize = 7
+)
+
+// NewWriter returns a WriteCloser that encrypts everything written to it into w
+// using the chunked format. Close must be called to seal the final chunk; it
+// does not close w. The plaintext digest in header is optional and is checked
+// by the reader when present. For headers with recipients key is the content
+// encryption key.
+func NewWriter(w io.Writer, key []byte, header Header) (io.WriteCloser, error) {
+	suite, err := cipherSuiteForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if header.ChunkSize == 0 {
+		header.ChunkSize = DefaultChunkSize
+	}
+	if header.ChunkSize < 0 || header.ChunkSize > maxChunkSize {
+		return nil, errors.Errorf("Invalid chunk size %d", header.ChunkSize)
+	}
+	header.Version = FormatVersion2
+	header.CipherSuite = suite
+
+	hdr, err := marshalHeader(&header)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err = io.ReadFull(rand.Reader, noncePrefix); err != nil {
+		return nil, errors.Wrap(err, "Error creating random nonce prefix")
+	}
+
+	if _, err = w.Write(append(hdr, noncePrefix...)); err != nil {
+		return nil, errors.Wrap(err, "Error writing model header")
+	}
+
+	return &streamWriter{
+		w:      w,
+		aead:   gcm,
+		ad:     hdr,
+		nonce:  newStreamNonce(noncePrefix),
+		buf:    make([]byte, 0, header.ChunkSize),
+		sealed: make([]byte, 0, header.ChunkSize+gcm.Overhead()),
+	}, nil
+}
+
+type streamWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	ad     []byte
+	nonce  *streamNonce
+	buf    []byte
+	sealed []byte
+	closed bool
+}
+
+func (sw *streamWriter) Write(p []byte) (int, error) {
+	if sw.closed {
+		return 0, errors.New("Write on closed stream")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// a full buffer is only sealed once more data arrives, so that the
+		// last chunk is always sealed by Close with the final flag set
+		if len(sw.buf) == cap(sw.buf) {
+			if err := sw.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(sw.buf[len(sw.buf):cap(sw.buf)], p)
+		sw.buf = sw.buf[:len(sw.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (sw *streamWriter) Close() error {
+	if sw.closed {
+		return nil
+	}
+	sw.closed = true
+	defer zeroizeByteArray(sw.buf[:cap(sw.buf)])
+	return sw.seal(true)
+}
+
+func (sw *streamWriter) seal(last bool) error {
+	nonce, err := sw.nonce.next(last)
+	if err != nil {
+		return err
+	}
+	sw.sealed = sw.aead.Seal(sw.sealed[:0], nonce, sw.buf, sw.ad)
+	sw.buf = sw.buf[:0]
+	if _, err = sw.w.Write(sw.sealed); err != nil {
+		return errors.Wrap(err, "Error writing encrypted chunk")
+	}
+	return nil
+}
+
+// Reader decrypts a model in the chunked format one chunk at a time
+type Reader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	ad      []byte
+	header  *Header
+	nonce   *streamNonce
+	digest  hash.Hash
+	chunk   []byte
+	plain   []byte
+	pending []byte
+	done    bool
+}
+
+// NewReader reads the header of a chunked model from r and returns a Reader
+// that decrypts and authenticates the payload chunk by chunk. Truncation is
+// reported as an error instead of io.EOF. key is either the model key or the
+// key of one of its recipients.
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	header, hdr, err := readHeader(br)
+	if err != nil {
+		return nil, err
+	}
+	if header.Version != FormatVersion2 {
+		return nil, errors.Errorf("Model format version %d is not chunked", header.Version)
+	}
+	if header.ChunkSize <= 0 || header.ChunkSize > maxChunkSize {
+		return nil, errors.Errorf("Invalid chunk size %d", header.ChunkSize)
+	}
+
+	key, cleanup, err := header.contentKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	suite, err := cipherSuiteForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if suite != header.CipherSuite {
+		return nil, errors.Errorf("Key does not match cipher suite %s", header.CipherSuite)
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	noncePrefix := make([]byte, noncePrefixSize)
+	if _, err = io.ReadFull(br, noncePrefix); err != nil {
+		return nil, errors.Wrap(err, "Error reading nonce prefix")
+	}
+
+	sr := &Reader{
+		r:      br,
+		aead:   gcm,
+		ad:     hdr,
+		header: header,
+		nonce:  newStreamNonce(noncePrefix),
+		chunk:  make([]byte, header.ChunkSize+gcm.Overhead()),
+	}
+	if header.PlaintextSHA384 != "" {
+		sr.digest = sha512.New384()
+	}
+	return sr, nil
+}
+
+// Header returns the header of the model being decrypted
+func (sr *Reader) Header() *Header {
+	return sr.header
+}
+
+// Close zeroizes the plaintext of the last decrypted chunk
+func (sr *Reader) Close() error {
+	zeroizeByteArray(sr.plain[:cap(sr.plain)])
+	sr.pending = nil
+	return nil
+}
+
+func (sr *Reader) Read(p []byte) (int, error) {
+	for len(sr.pending) == 0 {
+		if sr.done {
+			return 0, io.EOF
+		}
+		if err := sr.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, sr.pending)
+	sr.pending = sr.pending[n:]
+	return n, nil
+}
+
+func (sr *Reader) open() error {
+	n, err := io.ReadFull(sr.r, sr.chunk)
+	switch {
+	case err == io.EOF:
+		return errors.Wrap(ErrAuthentication, "Encrypted model is truncated")
+	case err == io.ErrUnexpectedEOF:
+		// a short chunk can only be the final one
+	case err != nil:
+		return errors.Wrap(err, "Error reading encrypted chunk")
+	}
+
+	last := n < len(sr.chunk)
+	if !last {
+		if _, err = sr.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return errors.Wrap(err, "Error reading encrypted chunk")
+		}
+	}
+
+	nonce, err := sr.nonce.next(last)
+	if err != nil {
+		return err
+	}
+	sr.plain, err = sr.aead.Open(sr.plain[:0], nonce, sr.chunk[:n], sr.ad)
+	if err != nil {
+		return errors.Wrap(ErrAuthentication, "Error decrypting chunk: model is corrupted, truncated or reordered")
+	}
+	sr.pending = sr.plain
+
+	if sr.digest != nil {
+		sr.digest.Write(sr.plain)
+	}
+	if last {
+		sr.done = true
+		return sr.verifyDigest()
+	}
+	return nil
+}
+
+func (sr *Reader) verifyDigest() error {
+	if sr.digest == nil {
+		return nil
+	}
+	expected, err := hex.DecodeString(sr.header.PlaintextSHA384)
+	if err != nil {
+		return errors.Wrap(err, "Invalid model digest in header")
+	}
+	if subtle.ConstantTimeCompare(expected, sr.digest.Sum(nil)) != 1 {
+		return errors.Wrap(ErrAuthentication, "Model digest does not match header")
+	}
+	return nil
+}
+
+// Decrypt returns a reader over the plaintext of an encrypted model in any of
+// the supported formats. Chunked models are decrypted while reading; enveloped
+// and legacy models are read and decrypted completely up front. Closing the
+// reader zeroizes the plaintext it holds. The returned header is nil for
+// legacy models.
+func Decrypt(r io.Reader, key []byte) (*Header, io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	prefix, err := br.Peek(prefixSize)
+	if err != nil && err != io.EOF {
+		return nil, nil, errors.Wrap(err, "Error reading encrypted model")
+	}
+
+	if IsEnvelope(prefix) && len(prefix) == prefixSize && prefix[len(Magic)] == FormatVersion2 {
+		sr, err := NewReader(br, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		return sr.Header(), sr, nil
+	}
+
+	data, err := io.ReadAll(br)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Error reading encrypted model")
+	}
+	header, model, err := Open(key, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, &plainTextReader{Reader: bytes.NewReader(model), model: model}, nil
+}
+
+// plainTextReader serves a model decrypted up front
+type plainTextReader struct {
+	*bytes.Reader
+	model []byte
+}
+
+// Close zeroizes the plaintext
+func (r *plainTextReader) Close() error {
+	zeroizeByteArray(r.model)
+	r.Reset(nil)
+	return nil
+}
+
+// ReadHeader reads the header of an encrypted model from r without decrypting
+// it. It returns a nil header for legacy models, which carry no metadata.
+func ReadHeader(r io.Reader) (*Header, error) {
+	br := bufio.NewReader(r)
+	prefix, err := br.Peek(len(Magic))
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "Error reading encrypted model")
+	}
+	if !IsEnvelope(prefix) {
+		return nil, nil
+	}
+
+	header, _, err := readHeader(br)
+	return header, err
+}
+
+// readHeader reads and parses the magic, version and header of an enveloped
+// model and returns the raw header bytes used as additional data
+func readHeader(r io.Reader) (*Header, []byte, error) {
+	prefix := make([]byte, prefixSize)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, nil, errors.Wrap(err, "Error reading model header")
+	}
+	if !IsEnvelope(prefix) {
+		return nil, nil, errors.New("Not an enveloped model")
+	}
+
+	hdrLen := binary.BigEndian.Uint32(prefix[len(Magic)+1:])
+	if hdrLen > maxHeaderSize {
+		return nil, nil, errors.New("Invalid model header length")
+	}
+	hdr := make([]byte, prefixSize+int(hdrLen))
+	copy(hdr, prefix)
+	if _, err := io.ReadFull(r, hdr[prefixSize:]); err != nil {
+		return nil, nil, errors.Wrap(err, "Error reading model header")
+	}
+
+	header, _, err := ParseHeader(hdr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return header, hdr, nil
+}
+
+type streamNonce struct {
+	nonce   []byte
+	counter uint64
+	final   bool
+}
+
+func newStreamNonce(prefix []byte) *streamNonce {
+	nonce := make([]byte, noncePrefixSize+5)
+	copy(nonce, prefix)
+	return &streamNonce{nonce: nonce}
+}
+
+func (sn *streamNonce) next(last bool) ([]byte, error) {
+	if sn.final {
+		return nil, errors.Wrap(ErrAuthentication, "Chunk after final chunk")
+	}
+	if sn.counter > math.MaxUint32 {
+		return nil, errors.New("Too many chunks")
+	}
+
+	binary.BigEndian.PutUint32(sn.nonce[noncePrefixSize:], uint32(sn.counter))
+	sn.nonce[len(sn.nonce)-1] = 0
+	if last {
+		sn.nonce[len(sn.nonce)-1] = 1
+		sn.final = true
+	}
+	sn.counter++
+	return sn.nonce, nil
+}
+
+func zeroizeByteArray(bytes []byte) {
+	for i := range bytes {
+		bytes[i] = 0
+	}
+}
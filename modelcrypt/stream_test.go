@@ -0,0 +1,175 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package modelcrypt
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+const testChunkSize = 16
+
+// sealStream encrypts model in the chunked format with testChunkSize chunks
+// and returns the encrypted model and the offset of its first chunk
+func sealStream(t *testing.T, key, model []byte) ([]byte, int) {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, key, Header{ChunkSize: testChunkSize, ModelName: "diabetes"})
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if _, err = w.Write(model); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	_, offset, err := ParseHeader(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ParseHeader: %v", err)
+	}
+	return buf.Bytes(), offset + noncePrefixSize
+}
+
+// sealChunks builds a chunked model by hand, sealing every chunk with the
+// given final flag
+func sealChunks(t *testing.T, key []byte, chunks [][]byte, last []bool) []byte {
+	t.Helper()
+	hdr, err := marshalHeader(&Header{Version: FormatVersion2, CipherSuite: CipherSuiteAES256GCM, ChunkSize: testChunkSize})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix := testKey(t, noncePrefixSize)
+	nonce := newStreamNonce(prefix)
+	out := append(append([]byte(nil), hdr...), prefix...)
+	for i, chunk := range chunks {
+		// the writer refuses chunks after a final one, forge them anyway
+		nonce.final = false
+		n, err := nonce.next(last[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = gcm.Seal(out, n, chunk, hdr)
+	}
+	return out
+}
+
+func readAll(key, data []byte) ([]byte, error) {
+	_, plainText, err := Decrypt(bytes.NewReader(data), key)
+	if err != nil {
+		return nil, err
+	}
+	defer plainText.Close()
+	return io.ReadAll(plainText)
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key := testKey(t, 32)
+
+	// empty, shorter than a chunk, a multiple of the chunk size and a
+	// partial final chunk
+	for _, size := range []int{0, 5, 2 * testChunkSize, 2*testChunkSize + 7} {
+		model := testKey(t, size)
+		data, _ := sealStream(t, key, model)
+
+		header, plainText, err := Decrypt(bytes.NewReader(data), key)
+		if err != nil {
+			t.Fatalf("Decrypt %d bytes: %v", size, err)
+		}
+		if header.Version != FormatVersion2 || header.ModelName != "diabetes" || header.ChunkSize != testChunkSize {
+			t.Fatalf("unexpected header %+v", header)
+		}
+		opened, err := io.ReadAll(plainText)
+		if err != nil {
+			t.Fatalf("ReadAll %d bytes: %v", size, err)
+		}
+		if !bytes.Equal(opened, model) {
+			t.Fatalf("decrypted %d bytes do not match the model", size)
+		}
+
+		sr := plainText.(*Reader)
+		sr.Close()
+		for _, b := range sr.plain[:cap(sr.plain)] {
+			if b != 0 {
+				t.Fatal("Close did not zeroize the last chunk")
+			}
+		}
+	}
+}
+
+func TestStreamRejectsTampering(t *testing.T) {
+	key := testKey(t, 32)
+	model := testKey(t, 3*testChunkSize+5)
+	data, offset := sealStream(t, key, model)
+	sealedChunk := testChunkSize + 16
+
+	chunk := func(i int) []byte {
+		return data[offset+i*sealedChunk : offset+(i+1)*sealedChunk]
+	}
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(append([][]byte{data[:offset]}, parts...), nil)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{
+			name: "dropped final chunk",
+			data: data[:offset+3*sealedChunk],
+		},
+		{
+			name: "dropped chunk",
+			data: join(chunk(0), chunk(2), data[offset+3*sealedChunk:]),
+		},
+		{
+			name: "swapped chunks",
+			data: join(chunk(1), chunk(0), chunk(2), data[offset+3*sealedChunk:]),
+		},
+		{
+			name: "duplicated chunk",
+			data: join(chunk(0), chunk(0), chunk(1), chunk(2), data[offset+3*sealedChunk:]),
+		},
+		{
+			name: "truncated final chunk",
+			data: data[:len(data)-1],
+		},
+		{
+			name: "no chunks",
+			data: data[:offset],
+		},
+		{
+			// a full chunk sealed as final followed by more data
+			name: "final flag set early",
+			data: sealChunks(t, key, [][]byte{model[:testChunkSize], model[testChunkSize : testChunkSize+5]}, []bool{true, true}),
+		},
+		{
+			// a stream whose final chunk is not flagged looks truncated
+			name: "final flag not set",
+			data: sealChunks(t, key, [][]byte{model[:testChunkSize], model[testChunkSize : testChunkSize+5]}, []bool{false, false}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readAll(key, tt.data); err == nil {
+				t.Fatal("tampered model was decrypted")
+			}
+		})
+	}
+
+	// the hand built stream is accepted with the correct flags
+	valid := sealChunks(t, key, [][]byte{model[:testChunkSize], model[testChunkSize : testChunkSize+5]}, []bool{false, true})
+	if opened, err := readAll(key, valid); err != nil || !bytes.Equal(opened, model[:testChunkSize+5]) {
+		t.Fatalf("valid stream: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intel/trustauthority-samples/tdxexample/auth"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// adminSocketPrefix marks admin listen addresses that are Unix socket paths
+const adminSocketPrefix = "unix:"
+
+// adminSocketMode restricts the admin socket to the service user
+const adminSocketMode = 0600
+
+// parseAdminAddress returns the network and address of ADMIN_LISTEN_ADDRESS,
+// either unix:<absolute path> or <host>:<port>
+func parseAdminAddress(address string) (string, string, error) {
+	if path, ok := strings.CutPrefix(address, adminSocketPrefix); ok {
+		if !filepath.IsAbs(path) {
+			return "", "", errors.New("Admin socket path must be absolute")
+		}
+		return "unix", filepath.Clean(path), nil
+	}
+
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", "", errors.New("Admin listen address must be unix:<path> or <host>:<port>")
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return "", "", errors.New("Admin listen port is not valid")
+	}
+	return "tcp", address, nil
+}
+
+// isLocalAddress reports whether only local processes can connect to the
+// address, which is the case for Unix sockets and loopback addresses
+func isLocalAddress(network, address string) bool {
+	if network == "unix" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// validateAdminListener checks the settings of the admin listener. TCP
+// listeners must authenticate callers, only the Unix socket, which is
+// restricted to the service user, may be unauthenticated. Listeners that can
+// be reached from other hosts must use TLS as well.
+func (conf *Configuration) validateAdminListener() []error {
+	network, address, err := parseAdminAddress(conf.AdminListenAddress)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	if network == "tcp" {
+		_, port, _ := net.SplitHostPort(address)
+		if port == strconv.Itoa(conf.Port) {
+			errs = append(errs, errors.New("Admin listen port must differ from the service port"))
+		}
+		if conf.AdminApiKey == "" && conf.AdminClientCAPath == "" {
+			errs = append(errs, errors.New("Admin listener on a TCP address requires an admin API key or client CA"))
+		}
+	}
+
+	withTLS := conf.AdminTLSCertPath != "" && conf.AdminTLSKeyPath != ""
+	if (conf.AdminTLSCertPath == "") != (conf.AdminTLSKeyPath == "") {
+		errs = append(errs, errors.New("Admin TLS certificate and key paths must both be set"))
+	}
+	if conf.AdminClientCAPath != "" && !withTLS {
+		errs = append(errs, errors.New("Admin client CA requires an admin TLS certificate"))
+	}
+
+	if !isLocalAddress(network, address) && !withTLS {
+		errs = append(errs, errors.New("Admin listener on a non-loopback address requires TLS"))
+	}
+
+	if conf.AdminApiKey != "" && conf.AdminApiKey == conf.AuthUserApiKey {
+		errs = append(errs, errors.New("Admin listener and user API keys must differ"))
+	}
+	return errs
+}
+
+// newAdminAuthenticator returns the authenticators of the admin listener,
+// which grant the admin role only. It returns nil if none is configured,
+// which Validate only allows for the Unix socket.
+func newAdminAuthenticator(conf *Configuration) auth.Authenticator {
+	var authenticators []auth.Authenticator
+
+	if conf.AdminApiKey != "" {
+		authenticators = append(authenticators, auth.NewAPIKeyAuthenticator(map[auth.Role]string{
+			auth.RoleAdmin: conf.AdminApiKey,
+		}))
+	}
+
+	// the TLS server only accepts certificates issued by the admin client CAs
+	if conf.AdminClientCAPath != "" {
+		authenticators = append(authenticators, auth.NewAdminClientCertAuthenticator())
+	}
+
+	if len(authenticators) == 0 {
+		log.Warnf("No admin authentication is configured, the operational routes are open to processes of the service user that connect to %s", conf.AdminListenAddress)
+		return nil
+	}
+	return auth.Chain(authenticators...)
+}
+
+// startAdminServer serves the operational routes of handler and /metrics of
+// gatherer on the admin listener. It returns the server and the source of its
+// TLS certificate, which is nil if the listener does not use TLS.
+func startAdminServer(conf *Configuration, handler http.Handler, gatherer prometheus.Gatherer) (*http.Server, certificateSource, error) {
+	network, address, err := parseAdminAddress(conf.AdminListenAddress)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	mux.Handle("/", handler)
+
+	adminServer := &http.Server{
+		Addr:              conf.AdminListenAddress,
+		Handler:           mux,
+		ReadHeaderTimeout: time.Duration(conf.HTTPReadHdrTimeout) * time.Second,
+	}
+
+	var certs certificateSource
+	if conf.AdminTLSCertPath != "" {
+		certs, err = newCertReloader(conf.AdminTLSCertPath, conf.AdminTLSKeyPath, "", conf.SanList)
+		if err != nil {
+			return nil, nil, err
+		}
+		adminServer.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS13,
+			GetCertificate: certs.GetCertificate,
+		}
+
+		if conf.AdminClientCAPath != "" {
+			clientCAs, err := loadClientCAs(conf.AdminClientCAPath)
+			if err != nil {
+				return nil, nil, err
+			}
+			adminServer.TLSConfig.ClientCAs = clientCAs
+			adminServer.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	listener, err := listenAdmin(network, address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	go func() {
+		log.Debugf("Starting admin server on %s", conf.AdminListenAddress)
+		var err error
+		if adminServer.TLSConfig != nil {
+			err = adminServer.ServeTLS(listener, "", "")
+		} else {
+			err = adminServer.Serve(listener)
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.WithError(err).Error("Admin server stopped")
+		}
+	}()
+	return adminServer, certs, nil
+}
+
+// listenAdmin listens on the admin address. A socket left behind by a
+// previous run is replaced and the new one is only accessible by the
+// service user, the directory of the socket should be private as well.
+func listenAdmin(network, address string) (net.Listener, error) {
+	if network == "unix" {
+		if fi, err := os.Lstat(address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(address); err != nil {
+				return nil, errors.Wrap(err, "Failed to remove stale admin socket")
+			}
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Failed to listen on admin address %s", address)
+	}
+
+	if network == "unix" {
+		if err := os.Chmod(address, adminSocketMode); err != nil {
+			listener.Close()
+			return nil, errors.Wrap(err, "Failed to restrict access to the admin socket")
+		}
+	}
+	return listener, nil
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import "testing"
+
+func TestValidateAdminListener(t *testing.T) {
+	withTLS := func(conf *Configuration) {
+		conf.AdminTLSCertPath = "/etc/admin.crt"
+		conf.AdminTLSKeyPath = "/etc/admin.key"
+	}
+	withApiKey := func(conf *Configuration) { conf.AdminApiKey = "admin-key" }
+
+	tests := []struct {
+		name    string
+		address string
+		setup   []func(*Configuration)
+		wantErr bool
+	}{
+		{name: "unix socket without auth", address: "unix:/run/trustauthority-demo/admin.sock"},
+		{name: "relative socket path", address: "unix:admin.sock", wantErr: true},
+		{name: "loopback without auth", address: "127.0.0.1:12782", wantErr: true},
+		{name: "localhost without auth", address: "localhost:12782", wantErr: true},
+		{name: "loopback with api key", address: "127.0.0.1:12782", setup: []func(*Configuration){withApiKey}},
+		{name: "remote with api key without tls", address: "0.0.0.0:12782", setup: []func(*Configuration){withApiKey}, wantErr: true},
+		{name: "remote with tls without auth", address: "0.0.0.0:12782", setup: []func(*Configuration){withTLS}, wantErr: true},
+		{name: "remote with tls and api key", address: "0.0.0.0:12782", setup: []func(*Configuration){withTLS, withApiKey}},
+		{name: "service port", address: "127.0.0.1:8443", setup: []func(*Configuration){withApiKey}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &Configuration{Port: 8443, MetricsPort: 12781, AdminListenAddress: tt.address}
+			for _, setup := range tt.setup {
+				setup(conf)
+			}
+			errs := conf.validateAdminListener()
+			if (len(errs) != 0) != tt.wantErr {
+				t.Fatalf("validateAdminListener returned %v, want errors %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+
+	"github.com/intel/trustauthority-samples/tdxexample/auth"
+	"github.com/intel/trustauthority-samples/tdxexample/jwks"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// newAuthenticator returns the authenticators enabled by the configuration,
+// tried in the order API key, JWT, client certificate. It returns nil if
+// AUTH_DISABLED is set, which leaves the REST API unauthenticated. The JWKS
+// is fetched with its own client, which verifies the server certificate
+// even if SKIP_TLS_VERIFICATION is set.
+func newAuthenticator(ctx context.Context, conf *Configuration) (auth.Authenticator, error) {
+	if conf.AuthDisabled {
+		log.Warn("Authentication is disabled, the REST API is open to anyone who can reach it")
+		return nil, nil
+	}
+
+	var authenticators []auth.Authenticator
+
+	if conf.AuthAdminApiKey != "" || conf.AuthUserApiKey != "" {
+		authenticators = append(authenticators, auth.NewAPIKeyAuthenticator(map[auth.Role]string{
+			auth.RoleAdmin: conf.AuthAdminApiKey,
+			auth.RoleUser:  conf.AuthUserApiKey,
+		}))
+	}
+
+	if conf.AuthJwksUrl != "" {
+		jwtAuthenticator, err := auth.NewJWTAuthenticator(ctx, auth.JWTOptions{
+			JWKSUrl:    conf.AuthJwksUrl,
+			Issuer:     conf.AuthJwtIssuer,
+			Audience:   conf.AuthJwtAudience,
+			RolesClaim: conf.AuthJwtRolesClaim,
+			HTTPClient: jwks.NewHTTPClient(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, jwtAuthenticator)
+	}
+
+	if conf.AuthClientCAPath != "" {
+		authenticators = append(authenticators, auth.NewClientCertAuthenticator(splitList(conf.AuthAdminClientNames)))
+	}
+
+	return auth.Chain(authenticators...), nil
+}
+
+// loadClientCAs reads the PEM encoded CA certificates client certificates
+// are verified against
+func loadClientCAs(path string) (*x509.CertPool, error) {
+	pemBytes, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read client CA certificates")
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, errors.Errorf("No client CA certificates found in %s", path)
+	}
+	return pool, nil
+}
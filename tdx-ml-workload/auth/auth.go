@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+// Package auth authenticates callers of the workload's REST API with static
+// API keys, JWT bearer tokens or TLS client certificates.
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Role grants access to a set of routes
+type Role string
+
+const (
+	// RoleUser may run inferences and read the workload status
+	RoleUser Role = "user"
+	// RoleAdmin may additionally manage keys, the model and provisioning
+	RoleAdmin Role = "admin"
+)
+
+// parseRole returns the role named s, or false if s names no role
+func parseRole(s string) (Role, bool) {
+	switch Role(s) {
+	case RoleUser, RoleAdmin:
+		return Role(s), true
+	}
+	return "", false
+}
+
+var (
+	// ErrNoCredentials is returned by authenticators when the request does
+	// not carry the credentials they handle
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when the credentials of a request
+	// are present but not valid
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	// Name identifies the caller in logs
+	Name string
+	// Method is the authentication method, e.g. "api-key"
+	Method string
+	Roles  []Role
+}
+
+// HasRole reports whether the principal was granted role. Admins have all
+// roles.
+func (p *Principal) HasRole(role Role) bool {
+	for _, r := range p.Roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator authenticates the caller of an HTTP request. It returns
+// ErrNoCredentials if the request carries none of the credentials it handles.
+type Authenticator interface {
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// Chain returns an authenticator that tries authenticators in order. The
+// first one that finds credentials in the request decides.
+func Chain(authenticators ...Authenticator) Authenticator {
+	return chain(authenticators)
+}
+
+type chain []Authenticator
+
+func (c chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range c {
+		principal, err := authenticator.Authenticate(r)
+		if err == ErrNoCredentials {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying the principal
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal carried by ctx, or nil
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
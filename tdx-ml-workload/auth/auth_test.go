@@ -0,0 +1,246 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	authenticator := NewAPIKeyAuthenticator(map[Role]string{RoleAdmin: "admin-key", RoleUser: "user-key"})
+
+	tests := []struct {
+		name    string
+		key     string
+		want    Role
+		wantErr error
+	}{
+		{name: "admin", key: "admin-key", want: RoleAdmin},
+		{name: "user", key: "user-key", want: RoleUser},
+		{name: "wrong key", key: "admin-key2", wantErr: ErrInvalidCredentials},
+		{name: "no key", wantErr: ErrNoCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/taa/v1/version", nil)
+			if tt.key != "" {
+				r.Header.Set(APIKeyHeader, tt.key)
+			}
+			principal, err := authenticator.Authenticate(r)
+			if err != tt.wantErr {
+				t.Fatalf("Authenticate returned %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(principal.Roles, []Role{tt.want}) {
+				t.Fatalf("Authenticate returned roles %v, want %s", principal.Roles, tt.want)
+			}
+		})
+	}
+
+	// an unset key does not match an empty header
+	userOnly := NewAPIKeyAuthenticator(map[Role]string{RoleAdmin: "", RoleUser: "user-key"})
+	r := httptest.NewRequest(http.MethodGet, "/taa/v1/version", nil)
+	r.Header.Set(APIKeyHeader, " ")
+	if _, err := userOnly.Authenticate(r); err != ErrInvalidCredentials {
+		t.Fatalf("Authenticate returned %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+// newTestJWKS serves a JWKS with the public key of signingKey
+func newTestJWKS(t *testing.T, signingKey *ecdsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	key, err := jwk.FromRaw(signingKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Set(jwk.KeyIDKey, "key-1")
+	key.Set(jwk.AlgorithmKey, "ES384")
+	set := jwk.NewSet()
+	set.AddKey(key)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestJWKS(t, signingKey)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	authenticator, err := NewJWTAuthenticator(ctx, JWTOptions{
+		JWKSUrl:    server.URL,
+		Issuer:     "https://issuer",
+		Audience:   "workload",
+		HTTPClient: server.Client(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(key *ecdsa.PrivateKey, claims jwt.MapClaims) string {
+		full := jwt.MapClaims{"sub": "caller", "iss": "https://issuer", "aud": "workload", "exp": time.Now().Add(time.Hour).Unix()}
+		for name, value := range claims {
+			full[name] = value
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodES384, full)
+		token.Header["kid"] = "key-1"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + signed
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantRoles     []Role
+		wantErr       error
+	}{
+		{name: "role list", authorization: sign(signingKey, jwt.MapClaims{"roles": []string{"user", "admin"}}), wantRoles: []Role{RoleUser, RoleAdmin}},
+		{name: "space separated roles", authorization: sign(signingKey, jwt.MapClaims{"roles": "user"}), wantRoles: []Role{RoleUser}},
+		{name: "unknown roles ignored", authorization: sign(signingKey, jwt.MapClaims{"roles": []string{"root", "user"}}), wantRoles: []Role{RoleUser}},
+		{name: "no roles", authorization: sign(signingKey, nil)},
+		{name: "wrong issuer", authorization: sign(signingKey, jwt.MapClaims{"iss": "https://other"}), wantErr: ErrInvalidCredentials},
+		{name: "wrong audience", authorization: sign(signingKey, jwt.MapClaims{"aud": "other"}), wantErr: ErrInvalidCredentials},
+		{name: "expired", authorization: sign(signingKey, jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), wantErr: ErrInvalidCredentials},
+		{name: "wrong key", authorization: sign(otherKey, nil), wantErr: ErrInvalidCredentials},
+		{name: "not a token", authorization: "Bearer token", wantErr: ErrInvalidCredentials},
+		{name: "basic auth", authorization: "Basic dXNlcjpwYXNz", wantErr: ErrNoCredentials},
+		{name: "no authorization", wantErr: ErrNoCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/taa/v1/version", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			principal, err := authenticator.Authenticate(r)
+			if err != tt.wantErr {
+				t.Fatalf("Authenticate returned %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if principal.Name != "jwt:caller" || !reflect.DeepEqual(principal.Roles, tt.wantRoles) {
+				t.Fatalf("Authenticate returned %+v, want roles %v", principal, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestClientCertAuthenticator(t *testing.T) {
+	authenticator := NewClientCertAuthenticator([]string{"operator"})
+	verified := func(name string) *tls.ConnectionState {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: name}}
+		return &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+
+	tests := []struct {
+		name    string
+		tls     *tls.ConnectionState
+		want    Role
+		wantErr error
+	}{
+		{name: "admin", tls: verified("operator"), want: RoleAdmin},
+		{name: "user", tls: verified("client"), want: RoleUser},
+		{
+			// certificates the server did not verify against the client
+			// CAs are ignored
+			name: "unverified",
+			tls: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{
+				{Subject: pkix.Name{CommonName: "operator"}},
+			}},
+			wantErr: ErrNoCredentials,
+		},
+		{name: "no tls", wantErr: ErrNoCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/taa/v1/version", nil)
+			r.TLS = tt.tls
+			principal, err := authenticator.Authenticate(r)
+			if err != tt.wantErr {
+				t.Fatalf("Authenticate returned %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(principal.Roles, []Role{tt.want}) {
+				t.Fatalf("Authenticate returned roles %v, want %s", principal.Roles, tt.want)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	authenticator := Chain(
+		NewAPIKeyAuthenticator(map[Role]string{RoleUser: "user-key"}),
+		NewClientCertAuthenticator([]string{"operator"}),
+	)
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "operator"}}
+	operator := &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+
+	// a wrong API key is rejected even though the client certificate is
+	// valid, the first authenticator that finds credentials decides
+	r := httptest.NewRequest(http.MethodGet, "/taa/v1/version", nil)
+	r.Header.Set(APIKeyHeader, "wrong")
+	r.TLS = operator
+	if _, err := authenticator.Authenticate(r); err != ErrInvalidCredentials {
+		t.Fatalf("Authenticate returned %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	r.Header.Del(APIKeyHeader)
+	principal, err := authenticator.Authenticate(r)
+	if err != nil || !principal.HasRole(RoleAdmin) {
+		t.Fatalf("Authenticate returned %+v, %v", principal, err)
+	}
+
+	r.TLS = nil
+	if _, err := authenticator.Authenticate(r); err != ErrNoCredentials {
+		t.Fatalf("Authenticate returned %v, want %v", err, ErrNoCredentials)
+	}
+}
+
+func TestHasRole(t *testing.T) {
+	admin := &Principal{Roles: []Role{RoleAdmin}}
+	user := &Principal{Roles: []Role{RoleUser}}
+	none := &Principal{}
+
+	if !admin.HasRole(RoleUser) || !admin.HasRole(RoleAdmin) {
+		t.Fatal("admins must have all roles")
+	}
+	if !user.HasRole(RoleUser) || user.HasRole(RoleAdmin) {
+		t.Fatal("users must only have the user role")
+	}
+	if none.HasRole(RoleUser) {
+		t.Fatal("principals without roles must have no role")
+	}
+}
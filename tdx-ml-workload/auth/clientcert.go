@@ -0,0 +1,48 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package auth
+
+import (
+	"net/http"
+)
+
+// ClientCertAuthenticator authenticates requests by the TLS client
+// certificate. The certificate must have been verified by the TLS server
+// against the client CAs, unverified certificates are not considered.
+type ClientCertAuthenticator struct {
+	admins      map[string]bool
+	defaultRole Role
+}
+
+// NewClientCertAuthenticator returns an authenticator granting the admin role
+// to certificates whose subject common name is one of adminNames and the user
+// role to all others
+func NewClientCertAuthenticator(adminNames []string) *ClientCertAuthenticator {
+	a := &ClientCertAuthenticator{admins: make(map[string]bool), defaultRole: RoleUser}
+	for _, name := range adminNames {
+		a.admins[name] = true
+	}
+	return a
+}
+
+// NewAdminClientCertAuthenticator returns an authenticator granting the admin
+// role to all certificates, for listeners whose client CAs only issue
+// certificates to administrators
+func NewAdminClientCertAuthenticator() *ClientCertAuthenticator {
+	return &ClientCertAuthenticator{defaultRole: RoleAdmin}
+}
+
+func (a *ClientCertAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+	role := a.defaultRole
+	if a.admins[name] {
+		role = RoleAdmin
+	}
+	return &Principal{Name: "cert:" + name, Method: "client-cert", Roles: []Role{role}}, nil
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/intel/trustauthority-samples/tdxexample/jwks"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultRolesClaim is the token claim the roles are read from
+	DefaultRolesClaim = "roles"
+
+	bearerPrefix = "Bearer "
+)
+
+// JWTOptions configures a JWTAuthenticator
+type JWTOptions struct {
+	// JWKSUrl serves the keys tokens are signed with
+	JWKSUrl string
+	// Issuer and Audience must match the iss and aud claims, if set
+	Issuer   string
+	Audience string
+	// RolesClaim holds a role name or a list of role names, DefaultRolesClaim
+	// if empty. Names that are no role are ignored.
+	RolesClaim string
+	// HTTPClient fetches the JWKS, jwks.NewHTTPClient if nil. It must verify
+	// the server certificate.
+	HTTPClient *http.Client
+}
+
+// JWTAuthenticator authenticates requests by a bearer token signed with a
+// key of a JWKS
+type JWTAuthenticator struct {
+	opts JWTOptions
+	keys *jwks.KeySet
+}
+
+// NewJWTAuthenticator returns a JWTAuthenticator. The JWKS is fetched on first
+// use and refreshed in the background until ctx is done.
+func NewJWTAuthenticator(ctx context.Context, opts JWTOptions) (*JWTAuthenticator, error) {
+	if opts.RolesClaim == "" {
+		opts.RolesClaim = DefaultRolesClaim
+	}
+
+	keys, err := jwks.NewKeySet(ctx, opts.JWKSUrl, opts.HTTPClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWTAuthenticator{opts: opts, keys: keys}, nil
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return nil, ErrNoCredentials
+	}
+
+	claims, err := a.keys.Parse(r.Context(), strings.TrimPrefix(header, bearerPrefix))
+	if err != nil {
+		log.WithError(err).Debug("Rejected bearer token")
+		return nil, ErrInvalidCredentials
+	}
+
+	if a.opts.Issuer != "" && !claims.VerifyIssuer(a.opts.Issuer, true) {
+		log.Debug("Rejected bearer token with wrong issuer")
+		return nil, ErrInvalidCredentials
+	}
+	if a.opts.Audience != "" && !claims.VerifyAudience(a.opts.Audience, true) {
+		log.Debug("Rejected bearer token with wrong audience")
+		return nil, ErrInvalidCredentials
+	}
+
+	subject, _ := claims["sub"].(string)
+	return &Principal{Name: "jwt:" + subject, Method: "jwt", Roles: rolesOf(claims[a.opts.RolesClaim])}, nil
+}
+
+// rolesOf returns the roles named by a roles claim
+func rolesOf(claim interface{}) []Role {
+	var names []string
+	switch v := claim.(type) {
+	case string:
+		names = strings.Fields(v)
+	case []interface{}:
+		for _, name := range v {
+			if s, ok := name.(string); ok {
+				names = append(names, s)
+			}
+		}
+	}
+
+	var roles []Role
+	for _, name := range names {
+		if role, ok := parseRole(name); ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
@@ -64,13 +64,6 @@ if [ "${NOSETUP,,}" == "true" ]; then
     echo "Update systemd configuration with Trust Authority details for manual startup"
     echo "Installation completed successfully!"
 else
-    # create config.json
-    CONFIG_JSON=$PRODUCT_HOME/config.json
-    echo "{\"trustauthority_api_url\": \"$TRUSTAUTHORITY_API_URL\"," > $CONFIG_JSON
-    echo " \"trustauthority_api_key\": \"$TRUSTAUTHORITY_API_KEY\"}" >> $CONFIG_JSON
-    chown $SERVICE_USERNAME:$SERVICE_USERNAME $CONFIG_JSON
-    chmod 600 $CONFIG_JSON
-
     # update systemd daemon configuration
     SYSD_CONF=$PRODUCT_HOME/$COMPONENT_NAME.service
     echo "Environment=TRUSTAUTHORITY_API_URL=$TRUSTAUTHORITY_API_URL" >> $SYSD_CONF
@@ -0,0 +1,119 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Exit codes of the config command
+const (
+	exitSuccess = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// redacted replaces secrets in printed configurations
+const redacted = "[REDACTED]"
+
+const usage = `Usage: trustauthority-demo [--config <file>] [command]
+
+Without command the workload service is started.
+
+Commands:
+  config print [--redacted=false]  Print the effective configuration as YAML
+
+Options:
+  --config <file>  YAML or JSON configuration file, environment variables
+                   override its settings
+`
+
+// parseArgs returns the configuration file and the command line arguments
+// that follow the options
+func parseArgs(args []string) (string, []string, error) {
+	var configPath string
+	fs := flag.NewFlagSet("trustauthority-demo", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&configPath, "config", "", "configuration file")
+	if err := fs.Parse(args); err != nil {
+		return "", nil, err
+	}
+	return configPath, fs.Args(), nil
+}
+
+// runCommand runs a command other than the service and returns the exit code
+func runCommand(configPath string, args []string) int {
+	if args[0] == "help" {
+		fmt.Fprint(os.Stdout, usage)
+		return exitSuccess
+	}
+	if len(args) < 2 || args[0] != "config" || args[1] != "print" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", strings.Join(args, " "), usage)
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	redact := fs.Bool("redacted", true, "replace secrets with "+redacted)
+	fs.StringVar(&configPath, "config", configPath, "configuration file")
+	if err := fs.Parse(args[2:]); err != nil {
+		if err == flag.ErrHelp {
+			return exitSuccess
+		}
+		return exitUsage
+	}
+
+	// only warnings about the configuration are of interest here
+	log.SetLevel(log.WarnLevel)
+	conf, err := NewConfig(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	if err := printConfig(os.Stdout, conf, *redact); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	if err := conf.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	return exitSuccess
+}
+
+// printConfig writes conf in the layout of the configuration file
+func printConfig(w io.Writer, conf *Configuration, redact bool) error {
+	sections := map[string]map[string]interface{}{
+		"logging": {"level": conf.LogLevel.String()},
+	}
+	for _, s := range settings {
+		section, name, _ := strings.Cut(s.key, ".")
+		if sections[section] == nil {
+			sections[section] = map[string]interface{}{}
+		}
+
+		value := conf.value(s.field)
+		if s.secret && redact && value != "" {
+			value = redacted
+		}
+		sections[section][name] = value
+	}
+
+	out, err := yaml.Marshal(sections)
+	if err != nil {
+		return errors.Wrap(err, "Failed to encode configuration")
+	}
+	_, err = w.Write(out)
+	return err
+}
@@ -6,9 +6,17 @@ package main
 
 import (
 	"encoding/base64"
+	"fmt"
+	"net"
 	"net/url"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
 
+	"github.com/intel/trustauthority-samples/tdxexample/auth"
+	"github.com/intel/trustauthority-samples/tdxexample/tracing"
+	httpTransport "github.com/intel/trustauthority-samples/tdxexample/transport/http"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
@@ -21,14 +29,71 @@ const (
 	envSanList                  = "SAN_LIST"
 	envSkipTlsVerification      = "SKIP_TLS_VERIFICATION"
 	envHttpReadHeaderTimeoutSec = "HTTP_READ_HEADER_TIMEOUT_IN_SECONDS"
+	envTlsCertPath              = "TLS_CERT_PATH"
+	envTlsKeyPath               = "TLS_KEY_PATH"
+	envTlsCAPath                = "TLS_CA_PATH"
+	envTlsAttestationEvidence   = "TLS_ATTESTATION_EVIDENCE"
 
 	envTrustAuthorityAPIUrl = "TRUSTAUTHORITY_API_URL"
 	envTrustAuthorityAPIKey = "TRUSTAUTHORITY_API_KEY"
+	// the API key may instead be read from a file, a systemd credential or
+	// a blob sealed with a KBS key
+	envTrustAuthorityAPIKeyFile       = "TRUSTAUTHORITY_API_KEY_FILE"
+	envTrustAuthorityAPIKeyCredential = "TRUSTAUTHORITY_API_KEY_CREDENTIAL"
+	envTrustAuthorityAPIKeySealedPath = "TRUSTAUTHORITY_API_KEY_SEALED_PATH"
+
+	envKeyTransferUrl              = "KEY_TRANSFER_URL"
+	envKbsAllowedUrls              = "KBS_ALLOWED_URLS"
+	envModelPath                   = "MODEL_PATH"
+	envPolicyIds                   = "POLICY_IDS"
+	envAutoLoadModel               = "AUTO_LOAD_MODEL"
+	envAutoLoadRetryIntervalSec    = "AUTO_LOAD_RETRY_INTERVAL_IN_SECONDS"
+	envAutoLoadMaxRetryIntervalSec = "AUTO_LOAD_MAX_RETRY_INTERVAL_IN_SECONDS"
+	envMetricsPort                 = "METRICS_PORT"
+	envMetricsHost                 = "METRICS_HOST"
+	envShutdownTimeoutSec          = "SHUTDOWN_TIMEOUT_IN_SECONDS"
+	envTracesExporter              = "OTEL_TRACES_EXPORTER"
+
+	envAuthAdminApiKey      = "AUTH_ADMIN_API_KEY"
+	envAuthUserApiKey       = "AUTH_USER_API_KEY"
+	envAuthJwksUrl          = "AUTH_JWKS_URL"
+	envAuthJwtIssuer        = "AUTH_JWT_ISSUER"
+	envAuthJwtAudience      = "AUTH_JWT_AUDIENCE"
+	envAuthJwtRolesClaim    = "AUTH_JWT_ROLES_CLAIM"
+	envAuthClientCAPath     = "AUTH_CLIENT_CA_PATH"
+	envAuthAdminClientNames = "AUTH_ADMIN_CLIENT_NAMES"
+	envAuthDisabled         = "AUTH_DISABLED"
+
+	envAdminListenAddress = "ADMIN_LISTEN_ADDRESS"
+	envAdminTlsCertPath   = "ADMIN_TLS_CERT_PATH"
+	envAdminTlsKeyPath    = "ADMIN_TLS_KEY_PATH"
+	envAdminClientCAPath  = "ADMIN_CLIENT_CA_PATH"
+	envAdminApiKey        = "ADMIN_API_KEY"
+
+	envCorsAllowedOrigins   = "CORS_ALLOWED_ORIGINS"
+	envCorsAllowedMethods   = "CORS_ALLOWED_METHODS"
+	envCorsAllowedHeaders   = "CORS_ALLOWED_HEADERS"
+	envCorsAllowCredentials = "CORS_ALLOW_CREDENTIALS"
+	envCorsMaxAgeSec        = "CORS_MAX_AGE_IN_SECONDS"
 
 	defaultSanList     = "127.0.0.1,localhost"
 	defaultPort        = "12780"
 	defaultLogLevel    = "info"
 	defaultHttpTimeout = "10"
+
+	defaultModelPath                = "/etc/model.enc"
+	defaultAutoLoadRetryInterval    = "5"
+	defaultAutoLoadMaxRetryInterval = "300"
+	defaultMetricsPort              = "12781"
+	defaultMetricsHost              = "127.0.0.1"
+	defaultShutdownTimeout          = "30"
+	defaultTracesExporter           = "none"
+
+	defaultCorsAllowedMethods = "GET,POST"
+	defaultCorsAllowedHeaders = "Accept,Content-Type,Authorization,X-Api-Key,X-Request-ID"
+	defaultCorsMaxAge         = "600"
+	// browsers cap the preflight cache, gorilla/handlers at 10 minutes
+	maxCorsMaxAge = 600
 )
 
 type Configuration struct {
@@ -38,14 +103,118 @@ type Configuration struct {
 	LogLevel            log.Level
 	SkipTLSVerification bool
 	HTTPReadHdrTimeout  int
+	TLSCertPath         string
+	TLSKeyPath          string
+	TLSCAPath           string
+	TLSEvidence         string
+
+	TrustAuthorityUrl           string
+	TrustAuthorityKey           string
+	TrustAuthorityKeyFile       string
+	TrustAuthorityKeyCredential string
+	TrustAuthorityKeySealedPath string
+
+	KeyTransferUrl string
+	KbsAllowedUrls string
+	ModelPath      string
+	PolicyIds      string
+
+	AutoLoadModel            bool
+	AutoLoadRetryInterval    int
+	AutoLoadMaxRetryInterval int
+
+	MetricsPort     int
+	MetricsHost     string
+	TracesExporter  string
+	ShutdownTimeout int
+
+	AuthAdminApiKey      string
+	AuthUserApiKey       string
+	AuthJwksUrl          string
+	AuthJwtIssuer        string
+	AuthJwtAudience      string
+	AuthJwtRolesClaim    string
+	AuthClientCAPath     string
+	AuthAdminClientNames string
+	AuthDisabled         bool
+
+	AdminListenAddress string
+	AdminTLSCertPath   string
+	AdminTLSKeyPath    string
+	AdminClientCAPath  string
+	AdminApiKey        string
+
+	CorsAllowedOrigins   string
+	CorsAllowedMethods   string
+	CorsAllowedHeaders   string
+	CorsAllowCredentials bool
+	CorsMaxAge           int
+}
 
-	TrustAuthorityUrl string
-	TrustAuthorityKey string
+// setting binds a Configuration field to its environment variable and its
+// key in the configuration file. Secrets are never logged or printed.
+type setting struct {
+	field  string
+	env    string
+	key    string
+	secret bool
 }
 
-func configure() (*Configuration, error) {
+// settings lists all fields of Configuration except LogLevel, which is
+// parsed separately. Environment variables override the configuration file.
+var settings = []setting{
+	{field: "Port", env: envServicePort, key: "service.port"},
+	{field: "SanList", env: envSanList, key: "service.san_list"},
+	{field: "HTTPReadHdrTimeout", env: envHttpReadHeaderTimeoutSec, key: "service.read_header_timeout_seconds"},
+	{field: "ShutdownTimeout", env: envShutdownTimeoutSec, key: "service.shutdown_timeout_seconds"},
+	{field: "MetricsPort", env: envMetricsPort, key: "service.metrics_port"},
+	{field: "MetricsHost", env: envMetricsHost, key: "service.metrics_host"},
+	{field: "LogCaller", env: envEnableLogCaller, key: "logging.caller"},
+	{field: "TracesExporter", env: envTracesExporter, key: "tracing.exporter"},
+	{field: "TLSCertPath", env: envTlsCertPath, key: "tls.cert_path"},
+	{field: "TLSKeyPath", env: envTlsKeyPath, key: "tls.key_path"},
+	{field: "TLSCAPath", env: envTlsCAPath, key: "tls.ca_path"},
+	{field: "TLSEvidence", env: envTlsAttestationEvidence, key: "tls.attestation_evidence"},
+	{field: "SkipTLSVerification", env: envSkipTlsVerification, key: "tls.skip_verification"},
+	{field: "TrustAuthorityUrl", env: envTrustAuthorityAPIUrl, key: "trust_authority.api_url"},
+	{field: "TrustAuthorityKey", env: envTrustAuthorityAPIKey, key: "trust_authority.api_key", secret: true},
+	{field: "TrustAuthorityKeyFile", env: envTrustAuthorityAPIKeyFile, key: "trust_authority.api_key_file"},
+	{field: "TrustAuthorityKeyCredential", env: envTrustAuthorityAPIKeyCredential, key: "trust_authority.api_key_credential"},
+	{field: "TrustAuthorityKeySealedPath", env: envTrustAuthorityAPIKeySealedPath, key: "trust_authority.api_key_sealed_path"},
+	{field: "PolicyIds", env: envPolicyIds, key: "trust_authority.policy_ids"},
+	{field: "KeyTransferUrl", env: envKeyTransferUrl, key: "kbs.key_transfer_url"},
+	{field: "KbsAllowedUrls", env: envKbsAllowedUrls, key: "kbs.allowed_urls"},
+	{field: "ModelPath", env: envModelPath, key: "model.path"},
+	{field: "AutoLoadModel", env: envAutoLoadModel, key: "model.auto_load"},
+	{field: "AutoLoadRetryInterval", env: envAutoLoadRetryIntervalSec, key: "model.auto_load_retry_interval_seconds"},
+	{field: "AutoLoadMaxRetryInterval", env: envAutoLoadMaxRetryIntervalSec, key: "model.auto_load_max_retry_interval_seconds"},
+	{field: "AuthAdminApiKey", env: envAuthAdminApiKey, key: "auth.admin_api_key", secret: true},
+	{field: "AuthUserApiKey", env: envAuthUserApiKey, key: "auth.user_api_key", secret: true},
+	{field: "AuthJwksUrl", env: envAuthJwksUrl, key: "auth.jwks_url"},
+	{field: "AuthJwtIssuer", env: envAuthJwtIssuer, key: "auth.jwt_issuer"},
+	{field: "AuthJwtAudience", env: envAuthJwtAudience, key: "auth.jwt_audience"},
+	{field: "AuthJwtRolesClaim", env: envAuthJwtRolesClaim, key: "auth.jwt_roles_claim"},
+	{field: "AuthClientCAPath", env: envAuthClientCAPath, key: "auth.client_ca_path"},
+	{field: "AuthAdminClientNames", env: envAuthAdminClientNames, key: "auth.admin_client_names"},
+	{field: "AuthDisabled", env: envAuthDisabled, key: "auth.disabled"},
+	{field: "AdminListenAddress", env: envAdminListenAddress, key: "admin.listen_address"},
+	{field: "AdminTLSCertPath", env: envAdminTlsCertPath, key: "admin.tls_cert_path"},
+	{field: "AdminTLSKeyPath", env: envAdminTlsKeyPath, key: "admin.tls_key_path"},
+	{field: "AdminClientCAPath", env: envAdminClientCAPath, key: "admin.client_ca_path"},
+	{field: "AdminApiKey", env: envAdminApiKey, key: "admin.api_key", secret: true},
+	{field: "CorsAllowedOrigins", env: envCorsAllowedOrigins, key: "cors.allowed_origins"},
+	{field: "CorsAllowedMethods", env: envCorsAllowedMethods, key: "cors.allowed_methods"},
+	{field: "CorsAllowedHeaders", env: envCorsAllowedHeaders, key: "cors.allowed_headers"},
+	{field: "CorsAllowCredentials", env: envCorsAllowCredentials, key: "cors.allow_credentials"},
+	{field: "CorsMaxAge", env: envCorsMaxAgeSec, key: "cors.max_age_seconds"},
+}
+
+// logLevelKey is the configuration file key of LogLevel
+const logLevelKey = "logging.level"
+
+func configure(configPath string) (*Configuration, error) {
 	log.SetFormatter(&log.JSONFormatter{})
-	c, err := NewConfigFromEnv()
+	c, err := NewConfig(configPath)
 	if err != nil {
 		return nil, err
 	}
@@ -54,7 +223,12 @@ func configure() (*Configuration, error) {
 	return c, nil
 }
 
-func NewConfigFromEnv() (*Configuration, error) {
+// NewConfig reads the configuration from the YAML or JSON file configPath,
+// if set, and from environment variables, which take precedence
+func NewConfig(configPath string) (*Configuration, error) {
+
+	// start over when the configuration is reloaded
+	viper.Reset()
 
 	// Setup defaults by associating the structure's field names to the defaults.
 	viper.SetDefault("Port", defaultPort)
@@ -62,73 +236,310 @@ func NewConfigFromEnv() (*Configuration, error) {
 	viper.SetDefault("SanList", defaultSanList)
 	viper.SetDefault("SkipTlsVerification", "false")
 	viper.SetDefault("HTTPReadHdrTimeout", defaultHttpTimeout)
+	viper.SetDefault("TLSCertPath", DefaultTLSCertPath)
+	viper.SetDefault("TLSKeyPath", DefaultTLSKeyPath)
+	viper.SetDefault("TLSEvidence", attestationEvidenceNone)
+	viper.SetDefault("ModelPath", defaultModelPath)
+	viper.SetDefault("AutoLoadModel", "false")
+	viper.SetDefault("AutoLoadRetryInterval", defaultAutoLoadRetryInterval)
+	viper.SetDefault("AutoLoadMaxRetryInterval", defaultAutoLoadMaxRetryInterval)
+	viper.SetDefault("MetricsPort", defaultMetricsPort)
+	viper.SetDefault("MetricsHost", defaultMetricsHost)
+	viper.SetDefault("TracesExporter", defaultTracesExporter)
+	viper.SetDefault("ShutdownTimeout", defaultShutdownTimeout)
+	viper.SetDefault("AuthJwtRolesClaim", auth.DefaultRolesClaim)
+	viper.SetDefault("AuthDisabled", "false")
+	viper.SetDefault("CorsAllowedMethods", defaultCorsAllowedMethods)
+	viper.SetDefault("CorsAllowedHeaders", defaultCorsAllowedHeaders)
+	viper.SetDefault("CorsAllowCredentials", "false")
+	viper.SetDefault("CorsMaxAge", defaultCorsMaxAge)
+
+	fileLevel := ""
+	if configPath != "" {
+		values, level, err := readConfigFile(configPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := viper.MergeConfigMap(values); err != nil {
+			return nil, errors.Wrapf(err, "Failed to merge config file %s", configPath)
+		}
+		fileLevel = level
+	}
 
 	// map structure field names to env var names (log level is handled manually below)
-	envBinding := map[string]string{
-		"Port":                envServicePort,
-		"SanList":             envSanList,
-		"LogCaller":           envEnableLogCaller,
-		"SkipTLSVerification": envSkipTlsVerification,
-		"HTTPReadHdrTimeout":  envHttpReadHeaderTimeoutSec,
-		"TrustAuthorityUrl":   envTrustAuthorityAPIUrl,
-		"TrustAuthorityKey":   envTrustAuthorityAPIKey,
-	}
-
-	for fieldName, envVar := range envBinding {
-		err := viper.BindEnv(fieldName, envVar)
+	for _, s := range settings {
+		err := viper.BindEnv(s.field, s.env)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Failed to bind env var %s to field %s", envVar, fieldName)
+			return nil, errors.Wrapf(err, "Failed to bind env var %s to field %s", s.env, s.field)
 		}
 	}
 
 	var conf Configuration
 	err := viper.Unmarshal(&conf)
 	if err != nil {
-		return nil, errors.Wrapf(err, "Failed to parse config from environment variables")
+		return nil, errors.Wrapf(err, "Failed to parse config")
 	}
 
-	envLevel := os.Getenv(envLogLevel)
-	if envLevel == "" {
+	level := os.Getenv(envLogLevel)
+	if level == "" {
+		level = fileLevel
+	}
+	if level == "" {
 		conf.LogLevel = log.InfoLevel
-	} else if logLevel, err := log.ParseLevel(envLevel); err != nil {
-		log.Warnf("Failed to parse log level %q, defaulting to 'info' level", envLevel)
+	} else if logLevel, err := log.ParseLevel(level); err != nil {
+		log.Warnf("Failed to parse log level %q, defaulting to 'info' level", level)
 		conf.LogLevel = log.InfoLevel
 	} else {
 		conf.LogLevel = logLevel
 	}
 
-	log.WithFields(log.Fields{
-		"Port":                conf.Port,
-		"SanList":             conf.SanList,
-		"LogLevel":            conf.LogLevel,
-		"LogCaller":           conf.LogCaller,
-		"SkipTLSVerification": conf.SkipTLSVerification,
-		"HTTPReadHdrTimeout":  conf.HTTPReadHdrTimeout,
-		"TrustAuthorityUrl":   conf.TrustAuthorityUrl,
-	}).Info("Parse configs from environment")
+	fields := log.Fields{"LogLevel": conf.LogLevel, "ConfigFile": configPath}
+	for _, s := range settings {
+		if !s.secret {
+			fields[s.field] = conf.value(s.field)
+		}
+	}
+	log.WithFields(fields).Info("Parsed configuration")
 
 	return &conf, nil
 }
 
+// readConfigFile returns the values of the file keyed by field name and the
+// log level. Lists are joined with commas like their environment variables.
+func readConfigFile(path string) (map[string]interface{}, string, error) {
+	file := viper.New()
+	file.SetConfigFile(path)
+	if err := file.ReadInConfig(); err != nil {
+		return nil, "", errors.Wrapf(err, "Failed to read config file %s", path)
+	}
+
+	known := map[string]string{}
+	for _, s := range settings {
+		known[s.key] = s.field
+	}
+
+	values := map[string]interface{}{}
+	var unknown []string
+	for _, key := range file.AllKeys() {
+		if key == logLevelKey {
+			continue
+		}
+		field, ok := known[key]
+		if !ok {
+			unknown = append(unknown, key)
+			continue
+		}
+
+		value := file.Get(key)
+		if list, ok := value.([]interface{}); ok {
+			items := make([]string, len(list))
+			for i, item := range list {
+				items[i] = fmt.Sprint(item)
+			}
+			value = strings.Join(items, ",")
+		}
+		values[field] = value
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, "", errors.Errorf("Unknown settings in config file %s: %s", path, strings.Join(unknown, ", "))
+	}
+	return values, file.GetString(logLevelKey), nil
+}
+
+// value returns the value of the named field
+func (conf *Configuration) value(field string) interface{} {
+	return reflect.ValueOf(conf).Elem().FieldByName(field).Interface()
+}
+
+// setValue sets the named field to value
+func (conf *Configuration) setValue(field string, value interface{}) {
+	reflect.ValueOf(conf).Elem().FieldByName(field).Set(reflect.ValueOf(value))
+}
+
+// corsOptions returns the CORS policy of the REST API
+func (conf *Configuration) corsOptions() httpTransport.CORSOptions {
+	return httpTransport.CORSOptions{
+		AllowedOrigins:   splitList(conf.CorsAllowedOrigins),
+		AllowedMethods:   splitList(conf.CorsAllowedMethods),
+		AllowedHeaders:   splitList(conf.CorsAllowedHeaders),
+		AllowCredentials: conf.CorsAllowCredentials,
+		MaxAge:           conf.CorsMaxAge,
+	}
+}
+
+// configErrors lists all problems of a configuration
+type configErrors []error
+
+func (e configErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "Invalid configuration:\n  " + strings.Join(msgs, "\n  ")
+}
+
+// Validate checks the configuration and reports all problems at once
 func (conf *Configuration) Validate() error {
+	var errs configErrors
 
 	if conf.Port < 1024 || conf.Port > 65535 {
-		return errors.New("Configured port is not valid")
+		errs = append(errs, errors.New("Configured port is not valid"))
+	}
+
+	// the Trust Authority credentials may instead be provisioned at runtime
+	keySources := 0
+	for _, source := range []string{conf.TrustAuthorityKey, conf.TrustAuthorityKeyFile, conf.TrustAuthorityKeyCredential, conf.TrustAuthorityKeySealedPath} {
+		if source != "" {
+			keySources++
+		}
+	}
+	if keySources > 1 {
+		errs = append(errs, errors.New("Only one source of the Trust Authority API key may be set"))
+	}
+	if keySources > 0 && conf.TrustAuthorityUrl == "" {
+		errs = append(errs, errors.New("Trust Authority API URL is missing"))
+	}
+	if strings.ContainsRune(conf.TrustAuthorityKeyCredential, os.PathSeparator) {
+		errs = append(errs, errors.New("Trust Authority API key credential must be a credential name, not a path"))
 	}
 
-	if conf.TrustAuthorityUrl == "" || conf.TrustAuthorityKey == "" {
-		return errors.New("Either Trust Authority API URL or APIKey is missing")
+	if conf.TrustAuthorityUrl != "" {
+		_, err := url.Parse(conf.TrustAuthorityUrl)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "Trust Authority API URL is not a valid url"))
+		}
 	}
 
-	_, err := url.Parse(conf.TrustAuthorityUrl)
-	if err != nil {
-		return errors.Wrap(err, "Trust Authority API URL is not a valid url")
+	if conf.TrustAuthorityKey != "" {
+		_, err := base64.StdEncoding.DecodeString(conf.TrustAuthorityKey)
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "Trust Authority ApiKey is not a valid base64 string"))
+		}
 	}
 
-	_, err = base64.StdEncoding.DecodeString(conf.TrustAuthorityKey)
-	if err != nil {
-		return errors.Wrap(err, "Trust Authority ApiKey is not a valid base64 string")
+	if conf.KeyTransferUrl != "" {
+		keyUrl, err := url.Parse(conf.KeyTransferUrl)
+		if err != nil || keyUrl.Scheme != "https" || keyUrl.Host == "" {
+			errs = append(errs, errors.New("Key transfer URL must be a valid https url"))
+		}
+	}
+
+	for _, allowed := range splitList(conf.KbsAllowedUrls) {
+		kbsUrl, err := url.Parse(allowed)
+		if err != nil || kbsUrl.Scheme != "https" || kbsUrl.Host == "" {
+			errs = append(errs, errors.Errorf("Allowed KBS URL %q must be a valid https url", allowed))
+		}
+	}
+
+	// metrics are disabled with port 0
+	if conf.MetricsPort != 0 && (conf.MetricsPort < 1024 || conf.MetricsPort > 65535 || conf.MetricsPort == conf.Port) {
+		errs = append(errs, errors.New("Configured metrics port is not valid"))
 	}
 
+	// the metrics listener is not authenticated, it is bound to an address
+	// rather than a host name so that it cannot be exposed by accident
+	if conf.MetricsPort != 0 && net.ParseIP(conf.MetricsHost) == nil {
+		errs = append(errs, errors.New("Configured metrics host must be an IP address"))
+	}
+
+	switch conf.TracesExporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout:
+	default:
+		errs = append(errs, errors.Errorf("Trace exporter must be one of %s, %s or %s", tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterStdout))
+	}
+
+	// an API without authentication has to be asked for explicitly
+	if !conf.authConfigured() && !conf.AuthDisabled {
+		errs = append(errs, errors.Errorf("No authentication is configured, set %s=true to serve the REST API without authentication", envAuthDisabled))
+	}
+	if conf.authConfigured() && conf.AuthDisabled {
+		errs = append(errs, errors.Errorf("%s cannot be combined with an authentication method", envAuthDisabled))
+	}
+
+	if conf.AuthAdminApiKey != "" && conf.AuthAdminApiKey == conf.AuthUserApiKey {
+		errs = append(errs, errors.New("Admin and user API keys must differ"))
+	}
+
+	if conf.AuthJwksUrl != "" {
+		jwksUrl, err := url.Parse(conf.AuthJwksUrl)
+		if err != nil || jwksUrl.Scheme != "https" || jwksUrl.Host == "" {
+			errs = append(errs, errors.New("JWKS URL must be a valid https url"))
+		}
+	}
+
+	if conf.AdminListenAddress != "" {
+		errs = append(errs, conf.validateAdminListener()...)
+	}
+
+	for _, origin := range splitList(conf.CorsAllowedOrigins) {
+		if origin == "*" {
+			if conf.CorsAllowCredentials {
+				errs = append(errs, errors.New("CORS credentials cannot be allowed for any origin"))
+			}
+			continue
+		}
+		originUrl, err := url.Parse(origin)
+		if err != nil || originUrl.Scheme == "" || originUrl.Host == "" || originUrl.Path != "" {
+			errs = append(errs, errors.Errorf("CORS origin %q must be * or scheme://host[:port]", origin))
+		}
+	}
+
+	if conf.CorsMaxAge < 0 || conf.CorsMaxAge > maxCorsMaxAge {
+		errs = append(errs, errors.Errorf("CORS max age must be between 0 and %d seconds", maxCorsMaxAge))
+	}
+
+	switch conf.TLSEvidence {
+	case attestationEvidenceNone:
+		if conf.TLSCertPath == "" || conf.TLSKeyPath == "" {
+			errs = append(errs, errors.New("TLS certificate and key paths must be set"))
+		}
+	case attestationEvidenceQuote, attestationEvidenceToken:
+	default:
+		errs = append(errs, errors.Errorf("TLS attestation evidence must be one of %s, %s or %s", attestationEvidenceNone, attestationEvidenceQuote, attestationEvidenceToken))
+	}
+
+	if conf.ShutdownTimeout < 1 {
+		errs = append(errs, errors.New("Shutdown timeout must be positive"))
+	}
+
+	if conf.ModelPath == "" {
+		errs = append(errs, errors.New("Model path is missing"))
+	}
+
+	if conf.AutoLoadModel && (conf.AutoLoadRetryInterval < 1 || conf.AutoLoadMaxRetryInterval < conf.AutoLoadRetryInterval) {
+		errs = append(errs, errors.New("Auto load retry intervals must be positive and the maximum not below the initial interval"))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
 }
+
+// authConfigured tells whether at least one authentication method of the
+// REST API is configured
+func (conf *Configuration) authConfigured() bool {
+	return conf.AuthAdminApiKey != "" || conf.AuthUserApiKey != "" || conf.AuthJwksUrl != "" || conf.AuthClientCAPath != ""
+}
+
+// allowedKbsUrls returns the KBS URLs whose key transfer URLs may be taken
+// from model headers, the configured key transfer URL included
+func (conf *Configuration) allowedKbsUrls() []string {
+	allowed := splitList(conf.KbsAllowedUrls)
+	if conf.KeyTransferUrl != "" {
+		allowed = append(allowed, conf.KeyTransferUrl)
+	}
+	return allowed
+}
+
+// splitList splits a comma separated list, ignoring empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
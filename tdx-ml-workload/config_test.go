@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// validConfig returns a configuration that passes Validate
+func validConfig() *Configuration {
+	return &Configuration{
+		Port:            6000,
+		TLSCertPath:     DefaultTLSCertPath,
+		TLSKeyPath:      DefaultTLSKeyPath,
+		TLSEvidence:     attestationEvidenceNone,
+		ModelPath:       defaultModelPath,
+		TracesExporter:  defaultTracesExporter,
+		AuthAdminApiKey: "admin-key",
+		ShutdownTimeout: 30,
+		CorsMaxAge:      600,
+	}
+}
+
+func TestValidateCORS(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     string
+		credentials bool
+		maxAge      int
+		wantErr     bool
+	}{
+		{name: "no origins"},
+		{name: "any origin", origins: "*"},
+		{name: "origin list", origins: "https://app.example.com, http://localhost:8080", credentials: true},
+		{name: "credentials for any origin", origins: "*", credentials: true, wantErr: true},
+		{name: "credentials for any origin in list", origins: "https://app.example.com,*", credentials: true, wantErr: true},
+		{name: "origin with path", origins: "https://app.example.com/ui", wantErr: true},
+		{name: "origin without scheme", origins: "app.example.com", wantErr: true},
+		{name: "negative max age", maxAge: -1, wantErr: true},
+		{name: "max age above limit", maxAge: maxCorsMaxAge + 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := validConfig()
+			conf.CorsAllowedOrigins = tt.origins
+			conf.CorsAllowCredentials = tt.credentials
+			conf.CorsMaxAge = tt.maxAge
+			if err := conf.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate returned %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeConfigFile writes a configuration file named name and resets viper
+// after the test
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	t.Cleanup(viper.Reset)
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewConfigFromFile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+service:
+  port: 7000
+  san_list: [127.0.0.1, localhost]
+logging:
+  level: debug
+trust_authority:
+  policy_ids: [policy-1, policy-2]
+model:
+  auto_load: true
+cors:
+  allowed_origins: https://app.example.com
+`)
+	t.Setenv(envServicePort, "8000")
+	t.Setenv(envCorsAllowedOrigins, "https://other.example.com")
+
+	conf, err := NewConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// environment variables take precedence over the file
+	if conf.Port != 8000 || conf.CorsAllowedOrigins != "https://other.example.com" {
+		t.Errorf("environment did not override the file: port %d, origins %q", conf.Port, conf.CorsAllowedOrigins)
+	}
+	if conf.LogLevel != log.DebugLevel || !conf.AutoLoadModel {
+		t.Errorf("file settings not applied: level %s, auto load %v", conf.LogLevel, conf.AutoLoadModel)
+	}
+	// lists are joined like their environment variables
+	if conf.SanList != "127.0.0.1,localhost" || conf.PolicyIds != "policy-1,policy-2" {
+		t.Errorf("lists not joined: san list %q, policy ids %q", conf.SanList, conf.PolicyIds)
+	}
+	// defaults apply to settings neither the file nor the environment set
+	if conf.ModelPath != defaultModelPath {
+		t.Errorf("model path %q, want the default %q", conf.ModelPath, defaultModelPath)
+	}
+}
+
+func TestNewConfigFromJSONFile(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{"service": {"port": 7000}, "kbs": {"allowed_urls": ["https://kbs-1", "https://kbs-2"]}}`)
+
+	conf, err := NewConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Port != 7000 || conf.KbsAllowedUrls != "https://kbs-1,https://kbs-2" {
+		t.Fatalf("port %d, allowed KBS URLs %q, want the values of the file", conf.Port, conf.KbsAllowedUrls)
+	}
+}
+
+func TestNewConfigUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+service:
+  port: 7000
+  prot: 7001
+modle:
+  path: /etc/model.enc
+`)
+
+	_, err := NewConfig(path)
+	if err == nil {
+		t.Fatal("NewConfig accepted unknown keys")
+	}
+	if !strings.Contains(err.Error(), "modle.path, service.prot") {
+		t.Fatalf("error %q does not list the unknown keys", err)
+	}
+}
+
+func TestValidateReportsAllErrors(t *testing.T) {
+	conf := validConfig()
+	conf.Port = 80
+	conf.TracesExporter = "jaeger"
+	conf.CorsMaxAge = -1
+	conf.ModelPath = ""
+
+	err := conf.Validate()
+	errs, ok := err.(configErrors)
+	if !ok {
+		t.Fatalf("Validate returned %v, want configErrors", err)
+	}
+	if len(errs) != 4 {
+		t.Fatalf("Validate returned %d errors, want 4: %v", len(errs), err)
+	}
+
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("Validate returned %v for a valid configuration", err)
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	conf := validConfig()
+	conf.TrustAuthorityKey = "ta-key"
+	conf.AuthUserApiKey = "user-key"
+	conf.SanList = "127.0.0.1,localhost"
+
+	tests := []struct {
+		name   string
+		redact bool
+	}{
+		{name: "redacted", redact: true},
+		{name: "not redacted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := printConfig(&out, conf, tt.redact); err != nil {
+				t.Fatal(err)
+			}
+			printed := out.String()
+
+			for _, secret := range []string{"ta-key", "admin-key", "user-key"} {
+				if strings.Contains(printed, secret) == tt.redact {
+					t.Errorf("secret %s printed %v with redact %v:\n%s", secret, !tt.redact, tt.redact, printed)
+				}
+			}
+			if strings.Contains(printed, redacted) != tt.redact {
+				t.Errorf("output contains %s %v, want %v", redacted, !tt.redact, tt.redact)
+			}
+			if !strings.Contains(printed, "san_list: 127.0.0.1,localhost") {
+				t.Errorf("output lacks the SAN list:\n%s", printed)
+			}
+		})
+	}
+
+	// unset secrets are printed empty, so that they can be told apart
+	var out bytes.Buffer
+	if err := printConfig(&out, validConfig(), true); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `user_api_key: ""`) {
+		t.Errorf("unset secret not printed empty:\n%s", out.String())
+	}
+}
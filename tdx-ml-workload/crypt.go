@@ -25,13 +25,18 @@ func ZeroizeBigInt(bigInt *big.Int) {
 		panic("The bigInt parameter cannot be nil")
 	}
 
-	bytes := make([]byte, len(bigInt.Bytes()))
-	bigInt.SetBytes(bytes)
+	// overwrite the words in place, setting a new value may leave them in
+	// memory
+	words := bigInt.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	bigInt.SetInt64(0)
 }
 
-// ZeroizeRSAPrivateKey clears the private key's "D" and
-// "Primes" (big int) values.  This function will panic if the privateKey
-// parameter is nil.
+// ZeroizeRSAPrivateKey clears the private key's "D", "Primes" and
+// precomputed CRT (big int) values.  This function will panic if the
+// privateKey parameter is nil.
 func ZeroizeRSAPrivateKey(privateKey *rsa.PrivateKey) {
 	if privateKey == nil {
 		panic("The private key parameter cannot be nil")
@@ -41,4 +46,9 @@ func ZeroizeRSAPrivateKey(privateKey *rsa.PrivateKey) {
 	for _, bigInt := range privateKey.Primes {
 		ZeroizeBigInt(bigInt)
 	}
+	for _, bigInt := range []*big.Int{privateKey.Precomputed.Dp, privateKey.Precomputed.Dq, privateKey.Precomputed.Qinv} {
+		if bigInt != nil {
+			ZeroizeBigInt(bigInt)
+		}
+	}
 }
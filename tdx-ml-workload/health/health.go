@@ -0,0 +1,209 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+// Package health runs registered health checks for the liveness and
+// readiness probes of the workload.
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Probe selects the checks that are run
+type Probe string
+
+const (
+	// Liveness checks fail when the process has to be restarted
+	Liveness Probe = "liveness"
+	// Readiness checks fail while the workload cannot serve requests
+	Readiness Probe = "readiness"
+)
+
+const (
+	StatusPass    = "pass"
+	StatusFail    = "fail"
+	StatusSkipped = "skipped"
+
+	// DefaultTimeout bounds the time a single check may take
+	DefaultTimeout = 5 * time.Second
+)
+
+// ErrSkipped is returned by checks that do not apply to the current
+// configuration. Skipped checks do not fail the probe.
+var ErrSkipped = errors.New("check skipped")
+
+// Checker checks one aspect of the workload's health
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to the Checker interface
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the outcome of all checks of a probe. Only the status is
+// encoded, the probes are served without authentication and check errors may
+// contain internal details.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"-"`
+}
+
+// Passed reports whether no check failed
+func (r *Report) Passed() bool {
+	return r.Status == StatusPass
+}
+
+type registration struct {
+	name    string
+	probe   Probe
+	checker Checker
+}
+
+// Registry holds the registered checks
+type Registry struct {
+	mu      sync.RWMutex
+	checks  []registration
+	timeout time.Duration
+}
+
+// NewRegistry returns an empty registry whose checks time out after timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a checker under name to the given probes. Registering a name
+// twice for the same probe replaces the earlier checker.
+func (r *Registry) Register(name string, checker Checker, probes ...Probe) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, probe := range probes {
+		replaced := false
+		for i := range r.checks {
+			if r.checks[i].name == name && r.checks[i].probe == probe {
+				r.checks[i].checker = checker
+				replaced = true
+			}
+		}
+		if !replaced {
+			r.checks = append(r.checks, registration{name: name, probe: probe, checker: checker})
+		}
+	}
+}
+
+// Check runs the checks of the given probes concurrently, or all checks when
+// no probe is given. A check registered for several probes runs once.
+func (r *Registry) Check(ctx context.Context, probes ...Probe) *Report {
+	checks := r.selected(probes)
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, checker Checker) {
+			defer wg.Done()
+			results[i] = r.run(ctx, checker)
+		}(i, check.checker)
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusPass, Checks: make(map[string]Result, len(checks))}
+	for i, check := range checks {
+		report.Checks[check.name] = results[i]
+		if results[i].Status == StatusFail {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (r *Registry) selected(probes []Probe) []registration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var checks []registration
+	for _, check := range r.checks {
+		if seen[check.name] || !matches(check.probe, probes) {
+			continue
+		}
+		seen[check.name] = true
+		checks = append(checks, check)
+	}
+	sort.Slice(checks, func(i, j int) bool { return checks[i].name < checks[j].name })
+	return checks
+}
+
+func matches(probe Probe, probes []Probe) bool {
+	if len(probes) == 0 {
+		return true
+	}
+	for _, p := range probes {
+		if p == probe {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Registry) run(ctx context.Context, checker Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	begin := time.Now()
+	err := checker.Check(ctx)
+	result := Result{Status: StatusPass, Duration: time.Since(begin).String()}
+	switch {
+	case err == ErrSkipped:
+		result.Status = StatusSkipped
+	case err != nil:
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Cached wraps checker so that its result is reused for ttl. Use it for
+// checks that are too expensive to run on every probe.
+func Cached(checker Checker, ttl time.Duration) Checker {
+	return &cachedChecker{checker: checker, ttl: ttl}
+}
+
+type cachedChecker struct {
+	checker Checker
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	err     error
+	checked time.Time
+}
+
+func (c *cachedChecker) Check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checked.IsZero() && time.Since(c.checked) < c.ttl {
+		return c.err
+	}
+	c.err = c.checker.Check(ctx)
+	c.checked = time.Now()
+	return c.err
+}
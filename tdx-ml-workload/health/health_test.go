@@ -0,0 +1,78 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestRegistry(t *testing.T) {
+	pass := CheckerFunc(func(context.Context) error { return nil })
+	fail := CheckerFunc(func(context.Context) error { return errors.New("https://kbs.internal:9443 is not reachable") })
+	skip := CheckerFunc(func(context.Context) error { return ErrSkipped })
+
+	registry := NewRegistry(DefaultTimeout)
+	registry.Register("live", pass, Liveness)
+	registry.Register("kbs", fail, Readiness)
+	registry.Register("evidence", skip, Readiness)
+
+	tests := []struct {
+		name   string
+		probes []Probe
+		status string
+		checks map[string]string
+	}{
+		{
+			name:   "liveness",
+			probes: []Probe{Liveness},
+			status: StatusPass,
+			checks: map[string]string{"live": StatusPass},
+		},
+		{
+			name:   "readiness",
+			probes: []Probe{Readiness},
+			status: StatusFail,
+			checks: map[string]string{"kbs": StatusFail, "evidence": StatusSkipped},
+		},
+		{
+			name:   "all",
+			status: StatusFail,
+			checks: map[string]string{"live": StatusPass, "kbs": StatusFail, "evidence": StatusSkipped},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := registry.Check(context.Background(), tt.probes...)
+			if report.Status != tt.status || len(report.Checks) != len(tt.checks) {
+				t.Fatalf("unexpected report %+v", report)
+			}
+			for name, status := range tt.checks {
+				if report.Checks[name].Status != status {
+					t.Fatalf("check %s has status %s, want %s", name, report.Checks[name].Status, status)
+				}
+			}
+		})
+	}
+}
+
+func TestReportOnlyEncodesStatus(t *testing.T) {
+	registry := NewRegistry(DefaultTimeout)
+	registry.Register("kbs", CheckerFunc(func(context.Context) error {
+		return errors.New("https://kbs.internal:9443 is not reachable")
+	}), Readiness)
+
+	data, err := json.Marshal(registry.Check(context.Background()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"status":"fail"}` {
+		t.Fatalf("report encoded as %s", data)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+// Package jwks verifies JWTs signed with a key of a JSON Web Key Set, as
+// issued by Intel Trust Authority or an identity provider.
+package jwks
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/pkg/errors"
+)
+
+const (
+	// MinRefreshInterval limits how often a JWKS is fetched again
+	MinRefreshInterval = 15 * time.Minute
+
+	httpClientTimeout = 30 * time.Second
+)
+
+// SigningMethods are the accepted token signing algorithms, symmetric
+// algorithms are rejected as the keys come from a public JWKS
+var SigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// NewHTTPClient returns a client to fetch a JWKS with. It always verifies
+// the server certificate, a JWKS fetched without would let anyone who can
+// intercept the connection sign tokens.
+func NewHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: httpClientTimeout,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12},
+		},
+	}
+}
+
+// KeySet looks up the verification keys of tokens in a fixed JWKS or in one
+// served by a URL
+type KeySet struct {
+	url   string
+	set   jwk.Set
+	cache *jwk.Cache
+}
+
+// NewKeySet returns a KeySet of the JWKS served by url, which is fetched
+// with httpClient on first use and refreshed in the background until ctx is
+// done. httpClient defaults to NewHTTPClient.
+func NewKeySet(ctx context.Context, url string, httpClient *http.Client) (*KeySet, error) {
+	if httpClient == nil {
+		httpClient = NewHTTPClient()
+	}
+
+	cache := jwk.NewCache(ctx)
+	err := cache.Register(url, jwk.WithHTTPClient(httpClient), jwk.WithMinRefreshInterval(MinRefreshInterval))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to register JWKS url")
+	}
+	return &KeySet{url: url, cache: cache}, nil
+}
+
+// StaticKeySet returns a KeySet of set
+func StaticKeySet(set jwk.Set) *KeySet {
+	return &KeySet{set: set}
+}
+
+// Parse verifies the signature and validity period of a token and returns
+// its claims. Tokens without expiration time are rejected.
+func (k *KeySet) Parse(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods(SigningMethods))
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return k.verificationKey(ctx, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return nil, errors.New("Token has no expiration time")
+	}
+	return claims, nil
+}
+
+// verificationKey returns the key of the JWKS the token names in its kid
+// header
+func (k *KeySet) verificationKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, errors.New("kid field missing in token header")
+	}
+
+	set := k.set
+	if set == nil {
+		var err error
+		set, err = k.cache.Get(ctx, k.url)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to get JWKS")
+		}
+	}
+
+	key, found := set.LookupKeyID(kid)
+	if !found {
+		return nil, errors.Errorf("Could not find key %q in JWKS", kid)
+	}
+
+	var raw interface{}
+	if err := key.Raw(&raw); err != nil {
+		return nil, errors.Wrap(err, "Failed to get verification key")
+	}
+	return raw, nil
+}
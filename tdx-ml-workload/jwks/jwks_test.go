@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package jwks
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+func TestKeySetParse(t *testing.T) {
+	signingKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.FromRaw(signingKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Set(jwk.KeyIDKey, "key-1")
+	set := jwk.NewSet()
+	set.AddKey(key)
+	keys := StaticKeySet(set)
+
+	sign := func(method jwt.SigningMethod, signingKey interface{}, kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(signingKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	valid := jwt.MapClaims{"sub": "caller", "exp": time.Now().Add(time.Hour).Unix()}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "valid", token: sign(jwt.SigningMethodES384, signingKey, "key-1", valid)},
+		{name: "no expiration time", token: sign(jwt.SigningMethodES384, signingKey, "key-1", jwt.MapClaims{"sub": "caller"}), wantErr: true},
+		{name: "expired", token: sign(jwt.SigningMethodES384, signingKey, "key-1", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), wantErr: true},
+		{name: "unknown key", token: sign(jwt.SigningMethodES384, signingKey, "key-2", valid), wantErr: true},
+		{name: "symmetric algorithm", token: sign(jwt.SigningMethodHS256, []byte("secret"), "key-1", valid), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := keys.Parse(context.Background(), tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if claims["sub"] != "caller" {
+				t.Fatalf("Parse returned claims %v", claims)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientVerifiesCertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"keys":[]}`))
+	}))
+	defer server.Close()
+
+	if _, err := NewHTTPClient().Get(server.URL); err == nil {
+		t.Fatal("JWKS client accepted an untrusted server certificate")
+	}
+}
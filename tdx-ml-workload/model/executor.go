@@ -5,22 +5,30 @@
 package model
 
 // #cgo CFLAGS: -fno-strict-overflow -fno-delete-null-pointer-checks -fwrapv -fstack-protector-strong
+// #include <stdlib.h>
+// #include <string.h>
 // #include "model.h"
 import "C"
 
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
+	"io"
 	"os"
 	"path/filepath"
+	"sync"
+	"unsafe"
 
+	"github.com/intel/trustauthority-samples/modelcrypt"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
+// modelMu guards C.aimodelbuffer: predictions hold the read lock so that the
+// model is not freed or replaced while it is parsed
+var modelMu sync.RWMutex
+
 type ModelExecutor struct {
 	modelPath string
 	privKey   *rsa.PrivateKey
@@ -36,7 +44,9 @@ func NewModelExecutor(mpath string, pkey *rsa.PrivateKey) *ModelExecutor {
 func (m *ModelExecutor) ResetModel() error {
 	log.Debug("Resetting Model. ")
 
+	modelMu.Lock()
 	status := C.model_reset()
+	modelMu.Unlock()
 	if status != 0 {
 		log.Errorf("Resetting ML model failed! Error code: 0x%04x", status)
 		return errors.New("Resetting ML model failed!")
@@ -50,6 +60,9 @@ func (m *ModelExecutor) ExecuteModel(pregnancies float32,
 	dbf float32, age float32) (int, error) {
 	log.Debug("Executing Model. ")
 
+	modelMu.RLock()
+	defer modelMu.RUnlock()
+
 	var inferedValue C.int
 	status := C.model_predict(C.double(pregnancies),
 		C.double(glucose),
@@ -73,7 +86,7 @@ func (m *ModelExecutor) DecryptModel(wrappedSwk, wrappedDek []byte) error {
 	log.Debug("Decrypting Model. ")
 	// read ml model from a file
 	modelPath := filepath.Clean(m.modelPath)
-	cipherModel, err := os.ReadFile(modelPath)
+	cipherModel, err := os.Open(modelPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return errors.New("ML model file does not exist. Please check filepath again.")
@@ -81,38 +94,137 @@ func (m *ModelExecutor) DecryptModel(wrappedSwk, wrappedDek []byte) error {
 			return errors.Wrapf(err, "Unable to read ml model from file : %s", m.modelPath)
 		}
 	}
+	defer cipherModel.Close()
+
+	info, err := cipherModel.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read ml model from file : %s", m.modelPath)
+	}
 
-	if len(cipherModel) == 0 {
+	if info.Size() == 0 {
 		return errors.New("Size of ml model can't be zero!")
 	}
 
+	dek, err := m.UnwrapTransferredKey(wrappedSwk, wrappedDek)
+	if err != nil {
+		return err
+	}
+
+	header, plainText, err := modelcrypt.Decrypt(cipherModel, dek)
+	if err != nil {
+		return errors.Wrap(err, "Error while decrypting the model")
+	}
+	defer plainText.Close()
+
+	//Decrypt the model inside the TD
+	mod, modLen, err := readIntoCBuffer(plainText, info.Size())
+	if err != nil {
+		return errors.Wrap(err, "Error while decrypting the model")
+	}
+
+	if header != nil {
+		log.WithFields(log.Fields{
+			"ModelName":    header.ModelName,
+			"ModelVersion": header.ModelVersion,
+			"KeyID":        header.KeyID,
+			"Recipients":   len(header.Recipients),
+			"CipherSuite":  header.CipherSuite,
+		}).Debug("Successfully decrypted model")
+	} else {
+		log.Debug("Successfully decrypted legacy model")
+	}
+
+	// install the new model first and zeroize and free the previous one
+	// once no prediction can be using it anymore
+	modelMu.Lock()
+	previous := C.aimodelbuffer
+	previousLen := C.aimodelbuffer_len
+	C.aimodelbuffer = mod
+	C.aimodelbuffer_len = modLen
+	modelMu.Unlock()
+
+	if previous != nil {
+		zeroizeCBuffer(previous, previousLen)
+		C.free(unsafe.Pointer(previous))
+	}
+	return nil
+}
+
+// UnwrapTransferredKey returns the key a KBS transferred wrapped with the
+// workload's public key
+func (m *ModelExecutor) UnwrapTransferredKey(wrappedSwk, wrappedDek []byte) ([]byte, error) {
 	swk, err := UnwrapKey(wrappedSwk, m.privKey)
 	if err != nil {
-		return errors.Wrap(err, "Error while unwrapping the swk")
+		return nil, errors.Wrap(err, "Error while unwrapping the swk")
 	}
 	log.Debug("Successfully unwrapped swk")
 
+	if len(wrappedDek) < 12 {
+		return nil, errors.New("Wrapped dek is too short")
+	}
 	dek, err := Decrypt(swk, wrappedDek[12:])
 	if err != nil {
-		return errors.Wrap(err, "Error while decrypting the dek")
+		return nil, errors.Wrap(err, "Error while decrypting the dek")
 	}
 	log.Debug("Successfully decrypted dek")
+	return dek, nil
+}
 
-	model, err := Decrypt(dek, cipherModel)
+// ModelHeader returns the header of the encrypted model file without
+// decrypting it. The header is nil for legacy models.
+func (m *ModelExecutor) ModelHeader() (*modelcrypt.Header, error) {
+	cipherModel, err := os.Open(filepath.Clean(m.modelPath))
 	if err != nil {
-		return errors.Wrap(err, "Error while decrypting the model")
+		return nil, errors.Wrapf(err, "Unable to read ml model from file : %s", m.modelPath)
 	}
-	log.Debug("Successfully decrypted model")
+	defer cipherModel.Close()
 
-	//Decrypt the model inside the TD
-	mod := C.CBytes(model)
-	C.aimodelbuffer = (*C.char)(mod)
-	return nil
+	return modelcrypt.ReadHeader(cipherModel)
+}
+
+// readIntoCBuffer copies the plaintext into C memory, chunked models are
+// decrypted chunk by chunk straight into it. The Go buffers of plainText are
+// zeroized when it is closed. The plaintext is never
+// larger than the encrypted file, and the extra byte keeps the buffer NUL
+// terminated for the model parser. It returns the buffer and the size of
+// its allocation, which model_reset zeroizes.
+func readIntoCBuffer(plainText io.Reader, cipherTextSize int64) (*C.char, C.size_t, error) {
+	size := cipherTextSize + 1
+	buf := C.calloc(C.size_t(size), 1)
+	if buf == nil {
+		return nil, 0, errors.New("Unable to allocate memory for ml model")
+	}
+	model := unsafe.Slice((*byte)(buf), size)
+
+	n := 0
+	for {
+		read, err := plainText.Read(model[n : size-1])
+		n += read
+		if err == io.EOF {
+			break
+		}
+		if err == nil && int64(n) == size-1 {
+			err = errors.New("Decrypted ml model is larger than the encrypted file")
+		}
+		if err != nil {
+			zeroizeCBuffer((*C.char)(buf), C.size_t(size))
+			C.free(buf)
+			return nil, 0, err
+		}
+	}
+
+	return (*C.char)(buf), C.size_t(size), nil
+}
+
+// zeroizeCBuffer overwrites size bytes of the C allocation buf like
+// model_reset does, the compiler may not elide it
+func zeroizeCBuffer(buf *C.char, size C.size_t) {
+	C.explicit_bzero(unsafe.Pointer(buf), size)
 }
 
 func UnwrapKey(wrappedKey []byte, pri *rsa.PrivateKey) ([]byte, error) {
 
-	decryptedKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, pri, wrappedKey, nil)
+	decryptedKey, err := modelcrypt.UnwrapKey(pri, wrappedKey, modelcrypt.TransferKeyWrapHash)
 	if err != nil {
 		return nil, errors.Wrap(err, "Error while decrypting the swk")
 	}
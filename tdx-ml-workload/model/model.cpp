@@ -8,12 +8,16 @@
 
 // AI Model (Decrypted)
 char *aimodelbuffer = NULL;
+size_t aimodelbuffer_len = 0;
 
-// Use this function to reset any state the model might hold.
+// Use this function to reset any state the model might hold. The decrypted
+// model is zeroized before it is freed.
 int model_reset() {
     if (aimodelbuffer != NULL) {
+      explicit_bzero(aimodelbuffer, aimodelbuffer_len);
       free(aimodelbuffer);
       aimodelbuffer = NULL;
+      aimodelbuffer_len = 0;
       std::cout << "Dropped the decrypted model reference.";
     } else {
       std::cout << "Model is already clean. Nothing to reset.";
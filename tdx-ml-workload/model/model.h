@@ -1,8 +1,12 @@
 #ifndef _MODEL_H_
 #define _MODEL_H_
 
+#include <stddef.h>
+
 // ML Model (Decrypted)
 extern char *aimodelbuffer;
+// Size of the allocation aimodelbuffer points to
+extern size_t aimodelbuffer_len;
 
 #ifdef __cplusplus
 extern "C" {
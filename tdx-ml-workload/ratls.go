@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"sync"
+	"time"
+
+	"github.com/intel/trustauthority-samples/tdxexample/ratls"
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Evidence embedded in the TLS certificate, see TLS_ATTESTATION_EVIDENCE
+const (
+	attestationEvidenceNone  = "none"
+	attestationEvidenceQuote = "quote"
+	attestationEvidenceToken = "token"
+)
+
+const (
+	// attested certificates are short-lived so that the evidence is fresh,
+	// they are renewed after half of their validity
+	attestedCertValidity    = 24 * time.Hour
+	attestedCertRenewPeriod = attestedCertValidity / 2
+)
+
+// attestedCertSource serves a self-signed certificate for a key that is
+// generated in the TD and never leaves its memory. The certificate carries a
+// quote binding the key, and optionally an Intel Trust Authority token.
+type attestedCertSource struct {
+	svc       service.Service
+	sanList   string
+	withToken bool
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	renewAt time.Time
+}
+
+func newAttestedCertSource(ctx context.Context, svc service.Service, sanList string, withToken bool) (*attestedCertSource, error) {
+	s := &attestedCertSource{svc: svc, sanList: sanList, withToken: withToken}
+	if err := s.renew(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (s *attestedCertSource) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cert, nil
+}
+
+// renew generates a new key and certificate with fresh evidence and serves
+// them
+func (s *attestedCertSource) renew(ctx context.Context) error {
+	install, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	install()
+	return nil
+}
+
+// load generates a new key and certificate with fresh evidence and returns a
+// function that serves them
+func (s *attestedCertSource) load(ctx context.Context) (func(), error) {
+	key, err := rsa.GenerateKey(rand.Reader, DefaultKeyLength)
+	if err != nil {
+		return nil, errors.Wrap(err, "error while generating RSA key pair")
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to marshal public key")
+	}
+
+	evidence, err := s.svc.GetKeyEvidence(ctx, service.KeyEvidenceRequest{PublicKey: publicKey, WithToken: s.withToken})
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to get attestation evidence for the TLS key")
+	}
+	extension, err := ratls.Extension(evidence)
+	if err != nil {
+		return nil, err
+	}
+
+	notAfter := time.Now().Add(attestedCertValidity)
+	der, err := selfSignedCertificate(key, s.sanList, notAfter, extension)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parse TLS certificate")
+	}
+
+	return func() {
+		s.mu.Lock()
+		s.cert = &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+		s.renewAt = time.Now().Add(attestedCertRenewPeriod)
+		s.mu.Unlock()
+
+		log.WithFields(log.Fields{
+			"NotAfter":  leaf.NotAfter,
+			"WithToken": s.withToken,
+		}).Info("Generated attested TLS certificate")
+	}, nil
+}
+
+// watch renews the certificate before it expires until ctx is done. Failed
+// renewals are retried while the current certificate is served.
+func (s *attestedCertSource) watch(ctx context.Context) {
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.RLock()
+			renewAt := s.renewAt
+			s.mu.RUnlock()
+			if time.Now().Before(renewAt) {
+				continue
+			}
+			if err := s.renew(ctx); err != nil {
+				log.WithError(err).Error("Failed to renew attested TLS certificate, serving the previous one")
+			}
+		}
+	}
+}
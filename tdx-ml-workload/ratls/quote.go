@@ -0,0 +1,63 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package ratls
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// Layout of a TDX quote version 4: a 48 byte header followed by the TD
+// report body. Only the measurement fields are parsed, the signature data is
+// verified by Intel Trust Authority.
+const (
+	quoteVersion4    = 4
+	quoteHeaderSize  = 48
+	tdReportBodySize = 584
+
+	mrtdOffset       = quoteHeaderSize + 136
+	rtmrOffset       = quoteHeaderSize + 328
+	reportDataOffset = quoteHeaderSize + 520
+
+	measurementSize = 48
+	reportDataSize  = 64
+	rtmrCount       = 4
+)
+
+// Quote holds the measurements of a TDX quote
+type Quote struct {
+	Version    uint16
+	MRTD       []byte
+	RTMRs      [rtmrCount][]byte
+	ReportData []byte
+}
+
+// ParseQuote parses the TD report body of a TDX quote version 4
+func ParseQuote(raw []byte) (*Quote, error) {
+	if len(raw) < quoteHeaderSize+tdReportBodySize {
+		return nil, errors.Errorf("Quote is too short: %d bytes", len(raw))
+	}
+
+	version := binary.LittleEndian.Uint16(raw[0:2])
+	if version != quoteVersion4 {
+		return nil, errors.Errorf("Unsupported quote version %d", version)
+	}
+
+	quote := &Quote{
+		Version:    version,
+		MRTD:       clone(raw[mrtdOffset : mrtdOffset+measurementSize]),
+		ReportData: clone(raw[reportDataOffset : reportDataOffset+reportDataSize]),
+	}
+	for i := range quote.RTMRs {
+		offset := rtmrOffset + i*measurementSize
+		quote.RTMRs[i] = clone(raw[offset : offset+measurementSize])
+	}
+	return quote, nil
+}
+
+func clone(b []byte) []byte {
+	return append([]byte(nil), b...)
+}
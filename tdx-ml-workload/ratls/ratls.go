@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+// Package ratls binds TDX attestation evidence to TLS certificates. The
+// workload embeds a quote, whose REPORTDATA is derived from the certificate's
+// public key, and optionally an Intel Trust Authority token in an X.509
+// extension, so that clients can verify they are connected to a TD.
+package ratls
+
+import (
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+
+	"github.com/pkg/errors"
+)
+
+// OIDEvidence identifies the evidence extension. The OID is specific to this
+// sample and not registered.
+var OIDEvidence = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 343, 1337, 1}
+
+// EvidenceVersion is the version of the evidence format
+const EvidenceVersion = 1
+
+// Evidence is the content of the evidence extension
+type Evidence struct {
+	Version int
+	// Nonce is the fresh random value mixed into the REPORTDATA
+	Nonce []byte
+	// Quote is the TDX quote whose REPORTDATA is ReportData(Nonce,
+	// public key of the certificate)
+	Quote []byte
+	// Token is an Intel Trust Authority attestation token whose attester
+	// held data is the public key of the certificate, if present
+	Token string `asn1:"utf8,optional"`
+}
+
+// ReportData returns the REPORTDATA of a quote bound to the DER encoded
+// SubjectPublicKeyInfo publicKey. It is computed as trustauthority-cli
+// computes it from the nonce and user data.
+func ReportData(nonce, publicKey []byte) []byte {
+	h := sha512.New()
+	h.Write(nonce)
+	h.Write(publicKey)
+	return h.Sum(nil)
+}
+
+// Extension returns the certificate extension carrying evidence
+func Extension(evidence *Evidence) (pkix.Extension, error) {
+	value, err := asn1.Marshal(*evidence)
+	if err != nil {
+		return pkix.Extension{}, errors.Wrap(err, "Failed to encode evidence")
+	}
+	return pkix.Extension{Id: OIDEvidence, Value: value}, nil
+}
+
+// ErrNoEvidence is returned for certificates without evidence extension
+var ErrNoEvidence = errors.New("certificate carries no attestation evidence")
+
+// EvidenceFromCertificate returns the evidence embedded in cert
+func EvidenceFromCertificate(cert *x509.Certificate) (*Evidence, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(OIDEvidence) {
+			continue
+		}
+
+		var evidence Evidence
+		rest, err := asn1.Unmarshal(ext.Value, &evidence)
+		if err != nil {
+			return nil, errors.Wrap(err, "Failed to decode evidence")
+		}
+		if len(rest) != 0 {
+			return nil, errors.New("Trailing data after evidence")
+		}
+		if evidence.Version != EvidenceVersion {
+			return nil, errors.Errorf("Unsupported evidence version %d", evidence.Version)
+		}
+		return &evidence, nil
+	}
+	return nil, ErrNoEvidence
+}
@@ -0,0 +1,209 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package ratls
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/intel/trustauthority-samples/tdxexample/jwks"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/pkg/errors"
+)
+
+// Policy lists the expected measurements as hex strings. Empty values are
+// not checked.
+type Policy struct {
+	MRTD  string
+	RTMRs [rtmrCount]string
+}
+
+// VerifierOptions configures a Verifier
+type VerifierOptions struct {
+	// Policy is enforced on the measurements of the quote
+	Policy Policy
+	// AllowQuoteOnly accepts certificates without token. Their quote's
+	// signature is not verified, so only clients that submit the quote to
+	// Intel Trust Authority themselves may set it. Certificates without
+	// token are rejected by default.
+	AllowQuoteOnly bool
+	// JWKSUrl serves the keys tokens are signed with, for Intel Trust
+	// Authority https://portal.trustauthority.intel.com/certs. KeySet is
+	// used instead if set.
+	JWKSUrl string
+	KeySet  jwk.Set
+	// HTTPClient fetches the JWKS, jwks.NewHTTPClient if nil. It must verify
+	// the server certificate.
+	HTTPClient *http.Client
+	// Issuer must match the iss claim of the token, if set
+	Issuer string
+}
+
+// Verifier verifies the attestation evidence of RA-TLS certificates
+type Verifier struct {
+	ctx    context.Context
+	opts   VerifierOptions
+	policy [1 + rtmrCount][]byte
+	keys   *jwks.KeySet
+}
+
+// NewVerifier returns a Verifier. A JWKS given by url is fetched on first use
+// and refreshed in the background until ctx is done.
+func NewVerifier(ctx context.Context, opts VerifierOptions) (*Verifier, error) {
+	v := &Verifier{ctx: ctx, opts: opts}
+
+	expected := append([]string{opts.Policy.MRTD}, opts.Policy.RTMRs[:]...)
+	for i, value := range expected {
+		if value == "" {
+			continue
+		}
+		measurement, err := hex.DecodeString(value)
+		if err != nil || len(measurement) != measurementSize {
+			return nil, errors.Errorf("Policy value %s must be %d hex encoded bytes", measurementName(i), measurementSize)
+		}
+		v.policy[i] = measurement
+	}
+
+	switch {
+	case opts.KeySet != nil:
+		v.keys = jwks.StaticKeySet(opts.KeySet)
+	case opts.JWKSUrl != "":
+		keys, err := jwks.NewKeySet(ctx, opts.JWKSUrl, opts.HTTPClient)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+	if !opts.AllowQuoteOnly && v.keys == nil {
+		return nil, errors.New("A JWKS is required to verify tokens unless quotes without token are allowed")
+	}
+	return v, nil
+}
+
+// TLSConfig returns a client configuration that accepts the self-signed
+// certificate of a workload only if its evidence passes Verify, which
+// requires a valid token unless AllowQuoteOnly is set
+func (v *Verifier) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		// the certificate is self-signed, it is trusted by its evidence
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: v.VerifyPeerCertificate,
+	}
+}
+
+// VerifyPeerCertificate implements tls.Config.VerifyPeerCertificate
+func (v *Verifier) VerifyPeerCertificate(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	if len(rawCerts) == 0 {
+		return errors.New("No peer certificate")
+	}
+	cert, err := x509.ParseCertificate(rawCerts[0])
+	if err != nil {
+		return errors.Wrap(err, "Failed to parse peer certificate")
+	}
+	_, _, err = v.Verify(cert)
+	return err
+}
+
+// Verify checks the validity period of cert, the binding of its quote to
+// the certificate key, the embedded token and the policy. It returns the
+// evidence and the parsed quote.
+func (v *Verifier) Verify(cert *x509.Certificate) (*Evidence, *Quote, error) {
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return nil, nil, errors.New("Certificate is not valid at this time")
+	}
+
+	evidence, quote, err := VerifyCertificate(cert)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case evidence.Token != "":
+		if v.keys == nil {
+			return nil, nil, errors.New("No JWKS configured to verify the token")
+		}
+		if err := v.verifyToken(evidence.Token, cert.RawSubjectPublicKeyInfo, quote); err != nil {
+			return nil, nil, err
+		}
+	case !v.opts.AllowQuoteOnly:
+		return nil, nil, errors.New("Certificate carries no attestation token")
+	}
+
+	if err := v.checkPolicy(quote); err != nil {
+		return nil, nil, err
+	}
+	return evidence, quote, nil
+}
+
+// verifyToken checks the signature of the token, that its attester held
+// data is the certificate's public key and that its measurements match the
+// quote
+func (v *Verifier) verifyToken(tokenString string, publicKey []byte, quote *Quote) error {
+	claims, err := v.keys.Parse(v.ctx, tokenString)
+	if err != nil {
+		return errors.Wrap(err, "Invalid attestation token")
+	}
+
+	if v.opts.Issuer != "" && !claims.VerifyIssuer(v.opts.Issuer, true) {
+		return errors.New("Attestation token has the wrong issuer")
+	}
+
+	heldData, err := base64.StdEncoding.DecodeString(tokenClaim(claims, "attester_held_data"))
+	if err != nil || subtle.ConstantTimeCompare(heldData, publicKey) != 1 {
+		return errors.New("Attestation token is not bound to the certificate key")
+	}
+
+	measurements := quote.measurements()
+	for i, claim := range []string{"tdx_mrtd", "tdx_rtmr0", "tdx_rtmr1", "tdx_rtmr2", "tdx_rtmr3"} {
+		value, err := hex.DecodeString(tokenClaim(claims, claim))
+		if err != nil || !bytes.Equal(value, measurements[i]) {
+			return errors.Errorf("%s of the attestation token does not match the quote", measurementName(i))
+		}
+	}
+	return nil
+}
+
+func (v *Verifier) checkPolicy(quote *Quote) error {
+	measurements := quote.measurements()
+	for i, expected := range v.policy {
+		if expected != nil && !bytes.Equal(expected, measurements[i]) {
+			return errors.Errorf("%s %x does not match the policy", measurementName(i), measurements[i])
+		}
+	}
+	return nil
+}
+
+// measurements returns MRTD followed by the RTMRs
+func (q *Quote) measurements() [1 + rtmrCount][]byte {
+	return [1 + rtmrCount][]byte{q.MRTD, q.RTMRs[0], q.RTMRs[1], q.RTMRs[2], q.RTMRs[3]}
+}
+
+func measurementName(i int) string {
+	return [...]string{"MRTD", "RTMR0", "RTMR1", "RTMR2", "RTMR3"}[i]
+}
+
+// tokenClaim returns a string claim of the token, which Intel Trust
+// Authority places at the top level or, in newer tokens, in the tdx claim
+func tokenClaim(claims jwt.MapClaims, name string) string {
+	if value, ok := claims[name].(string); ok {
+		return value
+	}
+	if tdx, ok := claims["tdx"].(map[string]interface{}); ok {
+		if value, ok := tdx[name].(string); ok {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package ratls
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+)
+
+// testEvidence describes the evidence of a test certificate
+type testEvidence struct {
+	// mrtd and rtmr fill the measurements of the quote
+	mrtd, rtmr byte
+	// reportDataKey replaces the public key the REPORTDATA is bound to
+	reportDataKey []byte
+	noToken       bool
+	// claims are merged into the token claims, nil values remove a claim
+	claims jwt.MapClaims
+	// signingKey replaces the key of the JWKS the token is signed with
+	signingKey *ecdsa.PrivateKey
+}
+
+// testQuote returns a TDX quote version 4 with the given REPORTDATA and
+// measurements
+func testQuote(reportData []byte, mrtd, rtmr byte) []byte {
+	raw := make([]byte, quoteHeaderSize+tdReportBodySize)
+	binary.LittleEndian.PutUint16(raw, quoteVersion4)
+	copy(raw[mrtdOffset:], bytes.Repeat([]byte{mrtd}, measurementSize))
+	for i := 0; i < rtmrCount; i++ {
+		copy(raw[rtmrOffset+i*measurementSize:], bytes.Repeat([]byte{rtmr + byte(i)}, measurementSize))
+	}
+	copy(raw[reportDataOffset:], reportData)
+	return raw
+}
+
+// measurementHex returns a measurement of testQuote as hex string
+func measurementHex(value byte) string {
+	return hex.EncodeToString(bytes.Repeat([]byte{value}, measurementSize))
+}
+
+// testAttester signs tokens with a key of its JWKS and issues RA-TLS
+// certificates
+type testAttester struct {
+	signingKey *ecdsa.PrivateKey
+	keySet     jwk.Set
+}
+
+func newTestAttester(t *testing.T) *testAttester {
+	t.Helper()
+	signingKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwk.FromRaw(signingKey.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Set(jwk.KeyIDKey, "attester")
+	keySet := jwk.NewSet()
+	keySet.AddKey(key)
+	return &testAttester{signingKey: signingKey, keySet: keySet}
+}
+
+// certificate returns a self-signed certificate carrying the evidence
+func (a *testAttester) certificate(t *testing.T, e testEvidence) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := []byte("nonce")
+	reportDataKey := publicKey
+	if e.reportDataKey != nil {
+		reportDataKey = e.reportDataKey
+	}
+	evidence := &Evidence{
+		Version: EvidenceVersion,
+		Nonce:   nonce,
+		Quote:   testQuote(ReportData(nonce, reportDataKey), e.mrtd, e.rtmr),
+	}
+
+	if !e.noToken {
+		claims := jwt.MapClaims{
+			"attester_held_data": base64.StdEncoding.EncodeToString(publicKey),
+			"tdx_mrtd":           measurementHex(e.mrtd),
+			"exp":                time.Now().Add(time.Hour).Unix(),
+		}
+		for i := 0; i < rtmrCount; i++ {
+			claims[fmt.Sprintf("tdx_rtmr%d", i)] = measurementHex(e.rtmr + byte(i))
+		}
+		for name, value := range e.claims {
+			if value == nil {
+				delete(claims, name)
+				continue
+			}
+			claims[name] = value
+		}
+		signingKey := a.signingKey
+		if e.signingKey != nil {
+			signingKey = e.signingKey
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodES384, claims)
+		token.Header["kid"] = "attester"
+		evidence.Token, err = token.SignedString(signingKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ext, err := Extension(evidence)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		NotBefore:       time.Now().Add(-time.Minute),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{ext},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestVerifierVerify(t *testing.T) {
+	attester := newTestAttester(t)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := Policy{MRTD: measurementHex(1), RTMRs: [rtmrCount]string{"", measurementHex(3)}}
+
+	tests := []struct {
+		name           string
+		evidence       testEvidence
+		allowQuoteOnly bool
+		// wantErr is part of the expected error message
+		wantErr string
+	}{
+		{name: "good quote and token", evidence: testEvidence{mrtd: 1, rtmr: 2}},
+		{name: "REPORTDATA not bound to the key", evidence: testEvidence{mrtd: 1, rtmr: 2, reportDataKey: []byte("other key")}, wantErr: "REPORTDATA is not bound"},
+		{name: "MRTD does not match the policy", evidence: testEvidence{mrtd: 9, rtmr: 2}, wantErr: "MRTD"},
+		{name: "RTMR does not match the policy", evidence: testEvidence{mrtd: 1, rtmr: 9}, wantErr: "RTMR1"},
+		{name: "token MRTD does not match the quote", evidence: testEvidence{mrtd: 1, rtmr: 2, claims: jwt.MapClaims{"tdx_mrtd": measurementHex(9)}}, wantErr: "MRTD of the attestation token"},
+		{name: "token RTMR does not match the quote", evidence: testEvidence{mrtd: 1, rtmr: 2, claims: jwt.MapClaims{"tdx_rtmr3": measurementHex(9)}}, wantErr: "RTMR3 of the attestation token"},
+		{name: "token signed by another key", evidence: testEvidence{mrtd: 1, rtmr: 2, signingKey: otherKey}, wantErr: "verification error"},
+		{name: "expired token", evidence: testEvidence{mrtd: 1, rtmr: 2, claims: jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}}, wantErr: "Token is expired"},
+		{name: "token without expiration time", evidence: testEvidence{mrtd: 1, rtmr: 2, claims: jwt.MapClaims{"exp": nil}}, wantErr: "no expiration time"},
+		{name: "token bound to another key", evidence: testEvidence{mrtd: 1, rtmr: 2, claims: jwt.MapClaims{"attester_held_data": base64.StdEncoding.EncodeToString([]byte("other key"))}}, wantErr: "not bound to the certificate key"},
+		{name: "missing token", evidence: testEvidence{mrtd: 1, rtmr: 2, noToken: true}, wantErr: "carries no attestation token"},
+		{name: "missing token allowed", evidence: testEvidence{mrtd: 1, rtmr: 2, noToken: true}, allowQuoteOnly: true},
+		{name: "quote only does not skip the policy", evidence: testEvidence{mrtd: 9, rtmr: 2, noToken: true}, allowQuoteOnly: true, wantErr: "does not match the policy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier, err := NewVerifier(context.Background(), VerifierOptions{
+				Policy:         policy,
+				KeySet:         attester.keySet,
+				AllowQuoteOnly: tt.allowQuoteOnly,
+			})
+			if err != nil {
+				t.Fatalf("NewVerifier: %v", err)
+			}
+
+			cert := attester.certificate(t, tt.evidence)
+			_, quote, err := verifier.Verify(cert)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Verify returned %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if hex.EncodeToString(quote.MRTD) != policy.MRTD {
+				t.Fatalf("Verify returned MRTD %x", quote.MRTD)
+			}
+
+			// TLSConfig verifies the peer certificate the same way
+			if err := verifier.TLSConfig().VerifyPeerCertificate([][]byte{cert.Raw}, nil); err != nil {
+				t.Fatalf("VerifyPeerCertificate: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewVerifierRequiresJWKS(t *testing.T) {
+	if _, err := NewVerifier(context.Background(), VerifierOptions{}); err == nil {
+		t.Fatal("NewVerifier accepted options that cannot verify tokens")
+	}
+	if _, err := NewVerifier(context.Background(), VerifierOptions{AllowQuoteOnly: true}); err != nil {
+		t.Fatalf("NewVerifier: %v", err)
+	}
+}
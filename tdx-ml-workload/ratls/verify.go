@@ -0,0 +1,35 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package ratls
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+
+	"github.com/pkg/errors"
+)
+
+// VerifyCertificate returns the evidence embedded in cert and its parsed
+// quote after checking that the quote's REPORTDATA is bound to the public
+// key of cert. It does not verify the quote signature, clients relying on
+// the measurements have to validate the embedded token or submit the quote
+// to Intel Trust Authority.
+func VerifyCertificate(cert *x509.Certificate) (*Evidence, *Quote, error) {
+	evidence, err := EvidenceFromCertificate(cert)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	quote, err := ParseQuote(evidence.Quote)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expected := ReportData(evidence.Nonce, cert.RawSubjectPublicKeyInfo)
+	if subtle.ConstantTimeCompare(expected, quote.ReportData) != 1 {
+		return nil, nil, errors.New("Quote REPORTDATA is not bound to the certificate key")
+	}
+	return evidence, quote, nil
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/intel/trustauthority-samples/tdxexample/secret"
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+	httpTransport "github.com/intel/trustauthority-samples/tdxexample/transport/http"
+	log "github.com/sirupsen/logrus"
+)
+
+// reloadableSettings are the fields applied by a reload, changes of all other
+// settings require a restart. LogLevel is reloadable as well.
+var reloadableSettings = map[string]bool{
+	"LogCaller":            true,
+	"PolicyIds":            true,
+	"CorsAllowedOrigins":   true,
+	"CorsAllowedMethods":   true,
+	"CorsAllowedHeaders":   true,
+	"CorsAllowCredentials": true,
+	"CorsMaxAge":           true,
+}
+
+// configReloader reads the configuration again and applies the settings that
+// can change at runtime. The TLS certificate and the Trust Authority API key
+// are read again on every reload.
+type configReloader struct {
+	configPath string
+	cors       *httpTransport.CORSPolicy
+
+	mu   sync.Mutex
+	conf *Configuration
+	// apiKeyDigest identifies the plain API key of the configuration, which
+	// is not kept in conf
+	apiKeyDigest [sha256.Size]byte
+	apiKey       *secret.Cache
+	certs        []certificateSource
+}
+
+func newConfigReloader(configPath string, conf *Configuration, cors *httpTransport.CORSPolicy) *configReloader {
+	return &configReloader{
+		configPath:   configPath,
+		conf:         conf,
+		cors:         cors,
+		apiKeyDigest: sha256.Sum256([]byte(conf.TrustAuthorityKey)),
+	}
+}
+
+// setSecrets sets the secrets that are read again on reload
+func (r *configReloader) setSecrets(apiKey *secret.Cache) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.apiKey = apiKey
+}
+
+// addCertificates adds a certificate that is reloaded on reload
+func (r *configReloader) addCertificates(certs certificateSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.certs = append(r.certs, certs)
+}
+
+// Reload implements service.Reloader
+func (r *configReloader) Reload(ctx context.Context) (*service.ReloadedSettings, *service.ReloadResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	conf, err := NewConfig(r.configPath)
+	if err == nil {
+		err = conf.Validate()
+	}
+	if err != nil {
+		return nil, nil, &service.HandledError{Code: http.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	// fail before applying anything if a certificate cannot be loaded, they
+	// are only served once all have loaded and the settings are applied
+	installCerts := make([]func(), 0, len(r.certs))
+	for _, certs := range r.certs {
+		install, err := certs.load(ctx)
+		if err != nil {
+			return nil, nil, &service.HandledError{Code: http.StatusUnprocessableEntity, Message: "Failed to reload TLS certificate: " + err.Error()}
+		}
+		installCerts = append(installCerts, install)
+	}
+
+	resp := &service.ReloadResponse{Reloaded: []string{}, RestartRequired: []string{}}
+	reloaded := &service.ReloadedSettings{}
+
+	if conf.LogLevel != r.conf.LogLevel {
+		r.conf.LogLevel = conf.LogLevel
+		log.SetLevel(conf.LogLevel)
+		resp.Reloaded = append(resp.Reloaded, envLogLevel)
+	}
+
+	apiKeyDigest := sha256.Sum256([]byte(conf.TrustAuthorityKey))
+	conf.TrustAuthorityKey = r.conf.TrustAuthorityKey
+	if apiKeyDigest != r.apiKeyDigest {
+		resp.RestartRequired = append(resp.RestartRequired, envTrustAuthorityAPIKey)
+	}
+
+	corsChanged := false
+	for _, s := range settings {
+		value := conf.value(s.field)
+		if value == r.conf.value(s.field) {
+			continue
+		}
+		if !reloadableSettings[s.field] {
+			resp.RestartRequired = append(resp.RestartRequired, s.env)
+			continue
+		}
+
+		r.conf.setValue(s.field, value)
+		resp.Reloaded = append(resp.Reloaded, s.env)
+		switch s.field {
+		case "LogCaller":
+			log.SetReportCaller(r.conf.LogCaller)
+		case "PolicyIds":
+			policyIds := r.conf.PolicyIds
+			reloaded.PolicyIds = &policyIds
+		default:
+			corsChanged = true
+		}
+	}
+	if corsChanged {
+		r.cors.Set(r.conf.corsOptions())
+	}
+
+	for _, install := range installCerts {
+		install()
+	}
+	if r.apiKey != nil {
+		r.apiKey.Reset()
+	}
+	return reloaded, resp, nil
+}
+
+// reloadOnSignal reloads the configuration on each signal of hup until ctx
+// is done
+func reloadOnSignal(ctx context.Context, hup <-chan os.Signal, svc service.Service) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			resp, err := svc.Reload(ctx)
+			if err != nil {
+				log.WithError(err).Error("Failed to reload the configuration")
+				continue
+			}
+			entry := log.WithField("Reloaded", resp.Reloaded)
+			if len(resp.RestartRequired) > 0 {
+				entry.WithField("RestartRequired", resp.RestartRequired).Warn("Reloaded the configuration, some settings require a restart")
+			} else {
+				entry.Info("Reloaded the configuration")
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+	httpTransport "github.com/intel/trustauthority-samples/tdxexample/transport/http"
+	log "github.com/sirupsen/logrus"
+)
+
+// fakeCertSource records whether a loaded certificate was installed
+type fakeCertSource struct {
+	loadErr   error
+	installed bool
+}
+
+func (s *fakeCertSource) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return nil, nil
+}
+
+func (s *fakeCertSource) watch(context.Context) {}
+
+func (s *fakeCertSource) load(context.Context) (func(), error) {
+	if s.loadErr != nil {
+		return nil, s.loadErr
+	}
+	return func() { s.installed = true }, nil
+}
+
+const reloadTestConfig = `
+service:
+  port: 6000
+logging:
+  level: info
+trust_authority:
+  policy_ids: policy-1
+auth:
+  admin_api_key: admin-key
+cors:
+  allowed_origins: https://app.example.com
+`
+
+// newTestReloader returns a reloader for the configuration file at path and
+// restores the global log settings a reload changes
+func newTestReloader(t *testing.T, path string) *configReloader {
+	t.Helper()
+	level, caller := log.GetLevel(), log.StandardLogger().ReportCaller
+	t.Cleanup(func() {
+		log.SetLevel(level)
+		log.SetReportCaller(caller)
+	})
+
+	conf, err := NewConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return newConfigReloader(path, conf, httpTransport.NewCORSPolicy(conf.corsOptions()))
+}
+
+func TestReload(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", reloadTestConfig)
+	r := newTestReloader(t, path)
+	certs := &fakeCertSource{}
+	r.addCertificates(certs)
+
+	if err := os.WriteFile(path, []byte(`
+service:
+  port: 7000
+logging:
+  level: debug
+trust_authority:
+  policy_ids: policy-2
+auth:
+  admin_api_key: admin-key
+cors:
+  allowed_origins: https://other.example.com
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, resp, err := r.Reload(context.Background())
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	wantReloaded := []string{envLogLevel, envPolicyIds, envCorsAllowedOrigins}
+	if !reflect.DeepEqual(resp.Reloaded, wantReloaded) {
+		t.Fatalf("reloaded %v, want %v", resp.Reloaded, wantReloaded)
+	}
+	if want := []string{envServicePort}; !reflect.DeepEqual(resp.RestartRequired, want) {
+		t.Fatalf("restart required %v, want %v", resp.RestartRequired, want)
+	}
+	if reloaded.PolicyIds == nil || *reloaded.PolicyIds != "policy-2" {
+		t.Fatalf("reloaded policy IDs %v, want policy-2", reloaded.PolicyIds)
+	}
+	if log.GetLevel() != log.DebugLevel {
+		t.Fatalf("log level %s, want debug", log.GetLevel())
+	}
+	if r.conf.Port != 6000 {
+		t.Fatalf("port changed to %d without restart", r.conf.Port)
+	}
+	if r.conf.CorsAllowedOrigins != "https://other.example.com" {
+		t.Fatalf("CORS allowed origins %q not reloaded", r.conf.CorsAllowedOrigins)
+	}
+	if !certs.installed {
+		t.Fatal("certificate not installed")
+	}
+
+	// a reload without changes reports nothing
+	reloaded, resp, err = r.Reload(context.Background())
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(resp.Reloaded) != 0 || reloaded.PolicyIds != nil {
+		t.Fatalf("unchanged reload applied %v", resp.Reloaded)
+	}
+	// the port still differs from the running one
+	if want := []string{envServicePort}; !reflect.DeepEqual(resp.RestartRequired, want) {
+		t.Fatalf("restart required %v, want %v", resp.RestartRequired, want)
+	}
+}
+
+func TestReloadFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		loadErr error
+	}{
+		{
+			name:   "invalid configuration",
+			config: "service:\n  port: 0\nlogging:\n  level: debug\ntrust_authority:\n  policy_ids: policy-2\n",
+		},
+		{
+			name:    "certificate load failure",
+			config:  "logging:\n  level: debug\ntrust_authority:\n  policy_ids: policy-2\nauth:\n  admin_api_key: admin-key\n",
+			loadErr: errors.New("no certificate"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yaml", reloadTestConfig)
+			r := newTestReloader(t, path)
+			// the service certificate loads, the admin certificate fails
+			serviceCerts := &fakeCertSource{}
+			adminCerts := &fakeCertSource{loadErr: tt.loadErr}
+			r.addCertificates(serviceCerts)
+			r.addCertificates(adminCerts)
+			level := log.GetLevel()
+
+			if err := os.WriteFile(path, []byte(tt.config), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, _, err := r.Reload(context.Background())
+			var handled *service.HandledError
+			if !errors.As(err, &handled) || handled.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("Reload error %v, want status %d", err, http.StatusUnprocessableEntity)
+			}
+
+			// nothing is applied when a reload fails
+			if log.GetLevel() != level {
+				t.Fatalf("log level changed to %s", log.GetLevel())
+			}
+			if r.conf.PolicyIds != "policy-1" {
+				t.Fatalf("policy IDs changed to %q", r.conf.PolicyIds)
+			}
+			if serviceCerts.installed || adminCerts.installed {
+				t.Fatal("certificate installed")
+			}
+		})
+	}
+}
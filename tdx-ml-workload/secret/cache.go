@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package secret
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Cache resolves a secret from its provider on first use and keeps it sealed
+// with a random key of the process, so that it is only in plain in memory
+// while it is used. Reset makes the next use read it again.
+type Cache struct {
+	provider Provider
+	aead     cipher.AEAD
+
+	mu          sync.Mutex
+	sealed      []byte
+	nonce       []byte
+	fingerprint string
+}
+
+// NewCache returns a cache of the secret of provider. A cache without
+// provider only holds the secrets passed to Set.
+func NewCache(provider Provider) (*Cache, error) {
+	sealingKey := make([]byte, 32)
+	if _, err := rand.Read(sealingKey); err != nil {
+		return nil, errors.Wrap(err, "could not create sealing key")
+	}
+	defer zeroize(sealingKey)
+
+	block, err := aes.NewCipher(sealingKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{provider: provider, aead: aead}, nil
+}
+
+// Resolve returns the secret, resolving it with the provider if it is not
+// cached. The caller should zeroize it once it is no longer needed.
+func (c *Cache) Resolve(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sealed == nil {
+		if c.provider == nil {
+			return nil, errors.New("No secret is set")
+		}
+		secret, err := c.provider.Resolve(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to resolve secret from %s", c.provider)
+		}
+		err = c.seal(secret)
+		if err != nil {
+			zeroize(secret)
+			return nil, err
+		}
+		return secret, nil
+	}
+
+	secret, err := c.aead.Open(nil, c.nonce, c.sealed, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not unseal secret")
+	}
+	return secret, nil
+}
+
+// Set replaces the cached secret
+func (c *Cache) Set(secret []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.seal(secret)
+}
+
+// Reset drops the cached secret, so that the next use resolves it again
+func (c *Cache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.provider == nil {
+		return
+	}
+	zeroize(c.sealed)
+	c.sealed = nil
+	c.fingerprint = ""
+}
+
+// Fingerprint identifies the cached secret by the first bytes of its
+// SHA-256 digest, it is empty while the secret is not resolved
+func (c *Cache) Fingerprint() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.fingerprint
+}
+
+func (c *Cache) String() string {
+	if c.provider == nil {
+		return "cache"
+	}
+	return c.provider.String()
+}
+
+func (c *Cache) seal(secret []byte) error {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "could not create nonce")
+	}
+
+	zeroize(c.sealed)
+	c.sealed = c.aead.Seal(nil, nonce, secret, nil)
+	c.nonce = nonce
+	digest := sha256.Sum256(secret)
+	c.fingerprint = hex.EncodeToString(digest[:4])
+	return nil
+}
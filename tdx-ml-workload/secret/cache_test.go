@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package secret
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// countingProvider returns its value and counts how often it was resolved
+type countingProvider struct {
+	value    string
+	resolved int
+}
+
+func (p *countingProvider) Resolve(context.Context) ([]byte, error) {
+	p.resolved++
+	return []byte(p.value), nil
+}
+
+func (p *countingProvider) String() string {
+	return "counting"
+}
+
+func TestCache(t *testing.T) {
+	provider := &countingProvider{value: "api-key"}
+	cache, err := NewCache(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if cache.Fingerprint() != "" {
+		t.Fatal("fingerprint of an unresolved secret")
+	}
+	for i := 0; i < 3; i++ {
+		secret, err := cache.Resolve(ctx)
+		if err != nil || string(secret) != "api-key" {
+			t.Fatalf("Resolve returned %q, %v", secret, err)
+		}
+	}
+	if provider.resolved != 1 {
+		t.Fatalf("provider resolved %d times, want once", provider.resolved)
+	}
+	// the secret is only kept sealed
+	if bytes.Contains(cache.sealed, []byte("api-key")) {
+		t.Fatal("cache holds the secret in plain")
+	}
+	fingerprint := cache.Fingerprint()
+	if fingerprint == "" {
+		t.Fatal("no fingerprint of the resolved secret")
+	}
+
+	// Reset makes the next use read the rotated secret
+	provider.value = "rotated-key"
+	cache.Reset()
+	if cache.Fingerprint() != "" {
+		t.Fatal("fingerprint kept after Reset")
+	}
+	secret, err := cache.Resolve(ctx)
+	if err != nil || string(secret) != "rotated-key" {
+		t.Fatalf("Resolve after Reset returned %q, %v", secret, err)
+	}
+	if provider.resolved != 2 || cache.Fingerprint() == fingerprint {
+		t.Fatalf("provider resolved %d times, fingerprint %s after Reset", provider.resolved, cache.Fingerprint())
+	}
+}
+
+func TestCacheWithoutProvider(t *testing.T) {
+	cache, err := NewCache(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := cache.Resolve(ctx); err == nil {
+		t.Fatal("Resolve succeeded without secret")
+	}
+	if err := cache.Set([]byte("provisioned-key")); err != nil {
+		t.Fatal(err)
+	}
+	// a set secret has no source to read it again from, Reset keeps it
+	cache.Reset()
+	secret, err := cache.Resolve(ctx)
+	if err != nil || string(secret) != "provisioned-key" {
+		t.Fatalf("Resolve returned %q, %v", secret, err)
+	}
+}
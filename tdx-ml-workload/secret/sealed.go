@@ -0,0 +1,100 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package secret
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/intel/trustauthority-samples/modelcrypt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// KeyFunc returns the key a KBS transfers for keyTransferUrl
+type KeyFunc func(ctx context.Context, keyTransferUrl string) ([]byte, error)
+
+type sealedProvider struct {
+	path string
+	key  KeyFunc
+}
+
+// Sealed returns a provider that decrypts the secret from a blob encrypted
+// with a KBS key, in the format the encryptor writes for models. The key is
+// transferred with key from the key transfer URLs recorded in the blob.
+func Sealed(path string, key KeyFunc) Provider {
+	return &sealedProvider{path: filepath.Clean(path), key: key}
+}
+
+func (p *sealedProvider) String() string {
+	return "sealed:" + p.path
+}
+
+func (p *sealedProvider) Resolve(ctx context.Context) ([]byte, error) {
+	blob, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read sealed secret")
+	}
+
+	header, err := modelcrypt.ReadHeader(bytes.NewReader(blob))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read sealed secret header")
+	}
+	urls := keyTransferUrls(header)
+	if len(urls) == 0 {
+		return nil, errors.Errorf("Sealed secret %s records no key transfer URL", p.path)
+	}
+
+	for _, url := range urls {
+		var secret []byte
+		secret, err = p.open(ctx, blob, url)
+		if err == nil {
+			return secret, nil
+		}
+		log.WithError(err).WithField("KeyTransferUrl", url).Warn("Could not unseal secret")
+	}
+	return nil, errors.Wrapf(err, "Failed to unseal %s", p.path)
+}
+
+func (p *sealedProvider) open(ctx context.Context, blob []byte, keyTransferUrl string) ([]byte, error) {
+	key, err := p.key(ctx, keyTransferUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroize(key)
+
+	_, plainText, err := modelcrypt.Decrypt(bytes.NewReader(blob), key)
+	if err != nil {
+		return nil, err
+	}
+	defer plainText.Close()
+	data, err := io.ReadAll(plainText)
+	if err != nil {
+		return nil, err
+	}
+	secret := append([]byte(nil), bytes.TrimSpace(data)...)
+	zeroize(data)
+	return secret, nil
+}
+
+func keyTransferUrls(header *modelcrypt.Header) []string {
+	if header == nil {
+		return nil
+	}
+
+	var urls []string
+	if header.KeyTransferURL != "" {
+		urls = append(urls, header.KeyTransferURL)
+	}
+	for _, recipient := range header.Recipients {
+		if recipient.KeyTransferURL != "" {
+			urls = append(urls, recipient.KeyTransferURL)
+		}
+	}
+	return urls
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+
+// Package secret resolves secrets like the Trust Authority API key from
+// files, systemd credentials or blobs sealed with a KBS key. Secrets are
+// read when they are needed and never logged.
+package secret
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// CredentialsDirectoryEnv is set by systemd to the directory of the
+// credentials passed with LoadCredential= or SetCredential=
+const CredentialsDirectoryEnv = "CREDENTIALS_DIRECTORY"
+
+// Provider resolves a secret on demand
+type Provider interface {
+	// Resolve returns the secret, the caller should zeroize it once it is
+	// no longer needed
+	Resolve(ctx context.Context) ([]byte, error)
+	// String describes the source without revealing the secret
+	String() string
+}
+
+type staticProvider struct {
+	value []byte
+}
+
+// Static returns a provider of a secret passed in plain, e.g. in an
+// environment variable
+func Static(value string) Provider {
+	return &staticProvider{value: []byte(value)}
+}
+
+func (p *staticProvider) Resolve(context.Context) ([]byte, error) {
+	return append([]byte(nil), p.value...), nil
+}
+
+func (p *staticProvider) String() string {
+	return "environment"
+}
+
+type fileProvider struct {
+	path string
+}
+
+// File returns a provider that reads the secret from path. The file must be
+// a regular file owned by the service user or root and must not be
+// accessible by group or others. path may be a symlink to such a file, like
+// the keys of a Kubernetes secret volume with defaultMode 0400. Surrounding
+// whitespace is removed.
+func File(path string) Provider {
+	return &fileProvider{path: filepath.Clean(path)}
+}
+
+func (p *fileProvider) Resolve(context.Context) ([]byte, error) {
+	return readSecretFile(p.path)
+}
+
+func (p *fileProvider) String() string {
+	return "file:" + p.path
+}
+
+type credentialProvider struct {
+	name string
+}
+
+// Credential returns a provider that reads the systemd credential name from
+// $CREDENTIALS_DIRECTORY
+func Credential(name string) Provider {
+	return &credentialProvider{name: name}
+}
+
+func (p *credentialProvider) Resolve(context.Context) ([]byte, error) {
+	dir := os.Getenv(CredentialsDirectoryEnv)
+	if dir == "" {
+		return nil, errors.Errorf("%s is not set, pass the credential with LoadCredential=", CredentialsDirectoryEnv)
+	}
+	if p.name == "" || strings.ContainsRune(p.name, os.PathSeparator) {
+		return nil, errors.Errorf("Invalid credential name %q", p.name)
+	}
+	return readSecretFile(filepath.Join(dir, p.name))
+}
+
+func (p *credentialProvider) String() string {
+	return "credential:" + p.name
+}
+
+// readSecretFile reads a secret file after checking its type, owner and
+// permissions. Symlinks are followed, the checks apply to the file they point
+// to. They are made on the opened file, so that it cannot be replaced between
+// the checks and the read.
+func readSecretFile(path string) ([]byte, error) {
+	// O_NONBLOCK keeps opening a FIFO from blocking before it is rejected,
+	// it has no effect on regular files
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to open secret file")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to stat secret file")
+	}
+	if !info.Mode().IsRegular() {
+		return nil, errors.Errorf("Secret file %s is not a regular file", path)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return nil, errors.Errorf("Secret file %s must not be accessible by group or others, its mode is %s", path, info.Mode().Perm())
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Uid != 0 && int(stat.Uid) != os.Geteuid() {
+		return nil, errors.Errorf("Secret file %s must be owned by the service user or root", path)
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read secret file")
+	}
+	secret := bytes.TrimSpace(data)
+	if len(secret) == 0 {
+		return nil, errors.Errorf("Secret file %s is empty", path)
+	}
+
+	value := append([]byte(nil), secret...)
+	zeroize(data)
+	return value, nil
+}
+
+func zeroize(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
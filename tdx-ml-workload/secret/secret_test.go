@@ -0,0 +1,194 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package secret
+
+import (
+	"context"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/intel/trustauthority-samples/modelcrypt"
+	"github.com/pkg/errors"
+)
+
+// writeSecret writes content with mode to name in dir
+func writeSecret(t *testing.T, dir, name, content string, mode os.FileMode) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	// WriteFile applies the umask
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	private := writeSecret(t, dir, "private", " api-key\n", 0600)
+	readOnly := writeSecret(t, dir, "read-only", "api-key", 0400)
+	groupReadable := writeSecret(t, dir, "group-readable", "api-key", 0640)
+	worldReadable := writeSecret(t, dir, "world-readable", "api-key", 0644)
+	empty := writeSecret(t, dir, "empty", " \n", 0600)
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(private, link); err != nil {
+		t.Fatal(err)
+	}
+	badLink := filepath.Join(dir, "bad-link")
+	if err := os.Symlink(worldReadable, badLink); err != nil {
+		t.Fatal(err)
+	}
+	fifo := filepath.Join(dir, "fifo")
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{name: "private", path: private},
+		{name: "read-only", path: readOnly},
+		// the checks apply to the file a symlink points to
+		{name: "symlink", path: link},
+		{name: "symlink to world-readable file", path: badLink, wantErr: "must not be accessible by group or others"},
+		{name: "group-readable", path: groupReadable, wantErr: "must not be accessible by group or others"},
+		{name: "world-readable", path: worldReadable, wantErr: "must not be accessible by group or others"},
+		{name: "empty", path: empty, wantErr: "is empty"},
+		{name: "directory", path: dir, wantErr: "is not a regular file"},
+		{name: "fifo", path: fifo, wantErr: "is not a regular file"},
+		{name: "missing", path: filepath.Join(dir, "missing"), wantErr: "Failed to open secret file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := File(tt.path).Resolve(context.Background())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve returned %v, want error %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			if string(secret) != "api-key" {
+				t.Fatalf("Resolve returned %q, want the trimmed secret", secret)
+			}
+		})
+	}
+}
+
+func TestCredential(t *testing.T) {
+	dir := t.TempDir()
+	writeSecret(t, dir, "trustauthority-api-key", "api-key", 0400)
+	writeSecret(t, dir, "readable", "api-key", 0644)
+
+	t.Setenv(CredentialsDirectoryEnv, dir)
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "trustauthority-api-key"},
+		{name: "readable", wantErr: true},
+		{name: "missing", wantErr: true},
+		{name: "../" + filepath.Base(dir) + "/trustauthority-api-key", wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := Credential(tt.name).Resolve(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Resolve returned %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && string(secret) != "api-key" {
+				t.Fatalf("Resolve returned %q", secret)
+			}
+		})
+	}
+
+	t.Setenv(CredentialsDirectoryEnv, "")
+	if _, err := Credential("trustauthority-api-key").Resolve(context.Background()); err == nil || !strings.Contains(err.Error(), CredentialsDirectoryEnv) {
+		t.Fatalf("Resolve returned %v without %s", err, CredentialsDirectoryEnv)
+	}
+}
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestSealed(t *testing.T) {
+	const (
+		primary = "https://kbs-1:9443/kbs/v1/keys/key-1/transfer"
+		backup  = "https://kbs-2:9443/kbs/v1/keys/key-2/transfer"
+	)
+	primaryKey, backupKey := newKey(t), newKey(t)
+	contentKey, err := modelcrypt.NewContentKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := modelcrypt.Header{}
+	for url, key := range map[string][]byte{primary: primaryKey, backup: backupKey} {
+		recipient, err := modelcrypt.WrapContentKey(key, contentKey, filepath.Base(filepath.Dir(url)), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header.Recipients = append(header.Recipients, recipient)
+	}
+	blob, err := modelcrypt.Seal(contentKey, header, []byte("api-key\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := writeSecret(t, t.TempDir(), "apikey.enc", string(blob), 0600)
+
+	// the primary KBS is unavailable, the key of the backup KBS is used
+	var requested []string
+	keyFunc := func(_ context.Context, keyTransferUrl string) ([]byte, error) {
+		requested = append(requested, keyTransferUrl)
+		if keyTransferUrl == primary {
+			return nil, errors.New("connection refused")
+		}
+		return append([]byte(nil), backupKey...), nil
+	}
+	secret, err := Sealed(path, keyFunc).Resolve(context.Background())
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if string(secret) != "api-key" {
+		t.Fatalf("Resolve returned %q", secret)
+	}
+	if len(requested) != 2 {
+		t.Fatalf("keys requested from %v, want both KBS", requested)
+	}
+
+	// a key of no recipient fails
+	wrongKey := func(context.Context, string) ([]byte, error) { return newKey(t), nil }
+	if _, err := Sealed(path, wrongKey).Resolve(context.Background()); err == nil {
+		t.Fatal("Resolve succeeded with a wrong key")
+	}
+
+	// the blob must record where its key is transferred from
+	noUrl, err := modelcrypt.Seal(primaryKey, modelcrypt.Header{}, []byte("api-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	noUrlPath := writeSecret(t, t.TempDir(), "apikey.enc", string(noUrl), 0600)
+	if _, err := Sealed(noUrlPath, keyFunc).Resolve(context.Background()); err == nil || !strings.Contains(err.Error(), "no key transfer URL") {
+		t.Fatalf("Resolve returned %v for a blob without key transfer URL", err)
+	}
+}
@@ -0,0 +1,30 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"github.com/intel/trustauthority-samples/tdxexample/secret"
+)
+
+// newApiKeyCache returns the Trust Authority API key of the configured
+// source, which is read when the first token is requested. It returns nil if
+// no source is configured and the key has to be provisioned.
+func newApiKeyCache(conf *Configuration, keyFunc secret.KeyFunc) (*secret.Cache, error) {
+	var provider secret.Provider
+	switch {
+	case conf.TrustAuthorityKeyFile != "":
+		provider = secret.File(conf.TrustAuthorityKeyFile)
+	case conf.TrustAuthorityKeyCredential != "":
+		provider = secret.Credential(conf.TrustAuthorityKeyCredential)
+	case conf.TrustAuthorityKeySealedPath != "":
+		provider = secret.Sealed(conf.TrustAuthorityKeySealedPath, keyFunc)
+	case conf.TrustAuthorityKey != "":
+		provider = secret.Static(conf.TrustAuthorityKey)
+		conf.TrustAuthorityKey = ""
+	default:
+		return nil, nil
+	}
+	return secret.NewCache(provider)
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// kbsAllowed reports whether keyTransferUrl, taken from an unauthenticated
+// model or secret header, belongs to one of the allowed KBS URLs. An allowed
+// URL matches key transfer URLs with the same scheme and host whose path is
+// below its path, e.g. https://kbs:9443/kbs/v1 allows
+// https://kbs:9443/kbs/v1/keys/<id>/transfer.
+func kbsAllowed(allowed []string, keyTransferUrl string) bool {
+	keyUrl, err := url.Parse(keyTransferUrl)
+	if err != nil || keyUrl.Scheme != "https" || keyUrl.User != nil {
+		return false
+	}
+	keyPath := path.Clean("/" + keyUrl.Path)
+
+	for _, entry := range allowed {
+		allowedUrl, err := url.Parse(entry)
+		if err != nil || allowedUrl.Scheme != keyUrl.Scheme || !strings.EqualFold(allowedUrl.Host, keyUrl.Host) {
+			continue
+		}
+		allowedPath := path.Clean("/" + allowedUrl.Path)
+		if allowedPath == "/" || keyPath == allowedPath || strings.HasPrefix(keyPath, allowedPath+"/") {
+			return true
+		}
+	}
+	return false
+}
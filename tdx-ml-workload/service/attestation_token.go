@@ -7,53 +7,102 @@ package service
 import (
 	"bytes"
 	"context"
-	"net/http"
+	"encoding/json"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 )
 
 const (
 	CLI = "trustauthority-cli"
+
+	// cliConfigPath is the configuration pipe in trustauthority-cli, the
+	// first of cmd.ExtraFiles is descriptor 3 of the child
+	cliConfigPath = "/dev/fd/3"
 )
 
 type GetAttestationTokenResponse struct {
 	AttestationToken string `json:"attestation_token"`
 }
 
-func (t *GetAttestationTokenResponse) Headers() http.Header {
-	return corsHeaders
-}
-
-func (mw loggingMiddleware) GetAttestationToken(ctx context.Context) (*GetAttestationTokenResponse, error) {
-	var err error
+func (mw loggingMiddleware) GetAttestationToken(ctx context.Context) (resp *GetAttestationTokenResponse, err error) {
 	defer func(begin time.Time) {
-		log.Tracef("GetAttestationToken took %s since %s", time.Since(begin), begin)
-		if err != nil {
-			log.WithError(err)
-		}
+		logCall(ctx, "GetAttestationToken", begin, nil, err)
 	}(time.Now())
-	resp, err := mw.next.GetAttestationToken(ctx)
-	return resp, err
+	return mw.next.GetAttestationToken(ctx)
+}
+
+// GetAttestationToken requests a token for the workload's key with the stored
+// Trust Authority credentials
+func (svc service) GetAttestationToken(ctx context.Context) (*GetAttestationTokenResponse, error) {
+	token, err := svc.attestationToken(ctx, svc.userData)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &GetAttestationTokenResponse{
+		AttestationToken: token,
+	}
+	return resp, nil
 }
 
-func (svc service) GetAttestationToken(_ context.Context) (*GetAttestationTokenResponse, error) {
+// attestationToken requests a token for the base64 encoded userData.
+// trustauthority-cli only reads the credentials from a configuration file, so
+// they are passed through a pipe only the child process inherits and never
+// written to disk.
+func (svc service) attestationToken(ctx context.Context, userData string) (string, error) {
+	apiUrl, apiKey, policyIds, err := svc.credentials.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer zeroizeByteArray(apiKey)
+
+	config, err := cliConfigPipe(apiUrl, apiKey)
+	if err != nil {
+		return "", err
+	}
+	defer config.Close()
 
-	var policyIds string
-	cmd := exec.Command(CLI, "token", "--config", "config.json", "--user-data", svc.userData, "--policy-ids", policyIds, "--no-eventlog")
+	cmd := exec.CommandContext(ctx, CLI, "token", "--config", cliConfigPath, "--user-data", userData, "--policy-ids", policyIds, "--no-eventlog")
+	cmd.ExtraFiles = []*os.File{config}
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
-		return nil, errors.Wrapf(err, "could not fetch token: %v", string(stderr.Bytes()))
+		return "", errors.Wrapf(err, "could not fetch token: %v", string(stderr.Bytes()))
 	}
 
-	resp := &GetAttestationTokenResponse{
-		AttestationToken: strings.TrimSpace(string(stdout.Bytes())),
+	return strings.TrimSpace(string(stdout.Bytes())), nil
+}
+
+type cliConfig struct {
+	ApiUrl string `json:"trustauthority_api_url"`
+	ApiKey string `json:"trustauthority_api_key"`
+}
+
+// cliConfigPipe returns the read end of a pipe holding the trustauthority-cli
+// configuration. The configuration is a few hundred bytes, so it fits into
+// the pipe buffer and the write end is closed before the CLI reads it.
+func cliConfigPipe(apiUrl string, apiKey []byte) (*os.File, error) {
+	data, err := json.Marshal(cliConfig{ApiUrl: apiUrl, ApiKey: string(apiKey)})
+	if err != nil {
+		return nil, err
 	}
-	return resp, nil
+	defer zeroizeByteArray(data)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create trustauthority-cli config pipe")
+	}
+	defer w.Close()
+
+	if _, err = w.Write(data); err != nil {
+		r.Close()
+		return nil, errors.Wrap(err, "could not write trustauthority-cli config")
+	}
+	return r, nil
 }
@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/intel/trustauthority-samples/tdxexample/secret"
+	"github.com/pkg/errors"
+)
+
+// Sources of the Trust Authority credentials. Credentials configured at
+// startup are described by the source of their API key, like
+// CredentialsFromEnvironment or file:<path>.
+const (
+	CredentialsFromEnvironment = "environment"
+	CredentialsFromProvision   = "provision"
+)
+
+// TrustAuthorityCredentials are used to request attestation tokens
+type TrustAuthorityCredentials struct {
+	ApiUrl string
+	// ApiKey resolves the API key when the first token is requested, nil
+	// if the credentials are provisioned at runtime
+	ApiKey *secret.Cache
+	// PolicyIds are the comma separated policy IDs tokens are requested
+	// with, may be empty
+	PolicyIds string
+}
+
+// credentialStore holds the Trust Authority credentials. The API key is kept
+// sealed by a secret.Cache, so that it is only in plain in memory while a
+// token is requested. It is shared by all copies of the service value.
+type credentialStore struct {
+	mu        sync.RWMutex
+	apiUrl    string
+	policyIds string
+	apiKey    *secret.Cache
+	source    string
+	updatedAt time.Time
+}
+
+func newCredentialStore() *credentialStore {
+	return &credentialStore{}
+}
+
+// set replaces the credentials with a provisioned API key. An empty API URL
+// keeps the current one, nil policy IDs keep the current ones.
+func (s *credentialStore) set(apiUrl, apiKey string, policyIds *string, source string) error {
+	cache, err := secret.NewCache(nil)
+	if err != nil {
+		return err
+	}
+	if err := cache.Set([]byte(apiKey)); err != nil {
+		return err
+	}
+	s.setCache(apiUrl, cache, policyIds, source)
+	return nil
+}
+
+// setCache replaces the credentials with the API key of cache
+func (s *credentialStore) setCache(apiUrl string, apiKey *secret.Cache, policyIds *string, source string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if apiUrl != "" {
+		s.apiUrl = apiUrl
+	}
+	if policyIds != nil {
+		s.policyIds = *policyIds
+	}
+	s.apiKey = apiKey
+	s.source = source
+	s.updatedAt = time.Now()
+}
+
+// setPolicyIds replaces the policy IDs tokens are requested with
+func (s *credentialStore) setPolicyIds(policyIds string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policyIds = policyIds
+}
+
+// get returns the credentials, resolving the API key if needed. The caller
+// should zeroize the API key once it is no longer needed.
+func (s *credentialStore) get(ctx context.Context) (string, []byte, string, error) {
+	s.mu.RLock()
+	apiUrl, apiKey, policyIds := s.apiUrl, s.apiKey, s.policyIds
+	s.mu.RUnlock()
+
+	if apiKey == nil {
+		return "", nil, "", &HandledError{
+			Code:    http.StatusConflict,
+			Message: "Trust Authority credentials are not provisioned",
+		}
+	}
+	key, err := apiKey.Resolve(ctx)
+	if err != nil {
+		return "", nil, "", errors.Wrap(err, "could not get Trust Authority API key")
+	}
+	return apiUrl, key, policyIds, nil
+}
+
+// status describes the credentials without revealing the API key
+func (s *credentialStore) status() *ProvisionStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.apiKey == nil {
+		return &ProvisionStatus{}
+	}
+	provisionedAt := s.updatedAt
+	return &ProvisionStatus{
+		Provisioned:       true,
+		Source:            s.source,
+		ApiUrl:            s.apiUrl,
+		PolicyIds:         s.policyIds,
+		ApiKeyFingerprint: s.apiKey.Fingerprint(),
+		ProvisionedAt:     &provisionedAt,
+	}
+}
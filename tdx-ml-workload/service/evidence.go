@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/intel/trustauthority-samples/tdxexample/ratls"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type KeyEvidenceRequest struct {
+	// PublicKey is the DER encoded SubjectPublicKeyInfo the evidence is
+	// bound to
+	PublicKey []byte
+	// WithToken adds an Intel Trust Authority token to the quote
+	WithToken bool
+}
+
+func (mw loggingMiddleware) GetKeyEvidence(ctx context.Context, req KeyEvidenceRequest) (resp *ratls.Evidence, err error) {
+	defer func(begin time.Time) {
+		logCall(ctx, "GetKeyEvidence", begin, log.Fields{"with_token": req.WithToken}, err)
+	}(time.Now())
+	return mw.next.GetKeyEvidence(ctx, req)
+}
+
+// GetKeyEvidence generates a quote for a fresh nonce whose REPORTDATA binds
+// the public key, as embedded in RA-TLS certificates
+func (svc service) GetKeyEvidence(ctx context.Context, req KeyEvidenceRequest) (*ratls.Evidence, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "could not create nonce")
+	}
+
+	publicKey := base64.StdEncoding.EncodeToString(req.PublicKey)
+	quote, _, err := collectEvidence(ctx, base64.StdEncoding.EncodeToString(nonce), publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get quote")
+	}
+
+	evidence := &ratls.Evidence{
+		Version: ratls.EvidenceVersion,
+		Nonce:   nonce,
+		Quote:   quote,
+	}
+	if req.WithToken {
+		evidence.Token, err = svc.attestationToken(ctx, publicKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get attestation token")
+		}
+	}
+	return evidence, nil
+}
@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/intel/trustauthority-samples/tdxexample/health"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// evidence and KBS checks call out of the TD, so their results are
+	// reused for a while instead of running on every probe
+	evidenceCheckTTL = 60 * time.Second
+	kbsCheckTTL      = 30 * time.Second
+)
+
+type HealthRequest struct {
+	// Probes selects the checks to run, all checks when empty
+	Probes []health.Probe
+}
+
+type HealthResponse struct {
+	health.Report
+}
+
+func (t *HealthResponse) StatusCode() int {
+	if t.Passed() {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+func (mw loggingMiddleware) CheckHealth(ctx context.Context, req HealthRequest) (resp *HealthResponse, err error) {
+	defer func(begin time.Time) {
+		logCall(ctx, "CheckHealth", begin, log.Fields{"probes": req.Probes}, err)
+	}(time.Now())
+	return mw.next.CheckHealth(ctx, req)
+}
+
+func (svc service) CheckHealth(ctx context.Context, req HealthRequest) (*HealthResponse, error) {
+	report := svc.checks.Check(ctx, req.Probes...)
+	for name, result := range report.Checks {
+		if result.Status == health.StatusFail {
+			log.WithField("check", name).WithField("duration", result.Duration).Warnf("Health check failed: %s", result.Error)
+		}
+	}
+	return &HealthResponse{*report}, nil
+}
+
+// registerHealthChecks registers the built-in checks of the workload
+func (svc service) registerHealthChecks() {
+	svc.checks.Register("model", health.CheckerFunc(svc.checkModelLoaded), health.Readiness)
+	svc.checks.Register("evidence", health.Cached(health.CheckerFunc(svc.checkEvidence), evidenceCheckTTL), health.Readiness)
+	svc.checks.Register("kbs", health.Cached(health.CheckerFunc(svc.checkKBS), kbsCheckTTL), health.Readiness)
+}
+
+func (svc service) checkModelLoaded(_ context.Context) error {
+	status := svc.model.get()
+	if status.State == ModelLoaded {
+		return nil
+	}
+	if status.LastError != "" {
+		return errors.Errorf("model is %s: %s", status.State, status.LastError)
+	}
+	return errors.Errorf("model is %s", status.State)
+}
+
+// checkEvidence generates a quote for a random nonce
+func (svc service) checkEvidence(ctx context.Context) error {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return errors.Wrap(err, "could not create nonce")
+	}
+
+	if _, _, err := collectEvidence(ctx, base64.StdEncoding.EncodeToString(nonce), svc.userData); err != nil {
+		return errors.Wrap(err, "could not generate evidence")
+	}
+	return nil
+}
+
+// checkKBS checks that the KBS of the configured key transfer URL, or of the
+// first one recorded in the model, answers HTTP requests. Any response,
+// including errors, counts as reachable.
+func (svc service) checkKBS(ctx context.Context) error {
+	header, err := svc.executor.ModelHeader()
+	if err != nil {
+		header = nil
+	}
+
+	urls := svc.keyTransferUrls(header)
+	if len(urls) == 0 {
+		return health.ErrSkipped
+	}
+
+	keyUrl, err := url.Parse(urls[0])
+	if err != nil {
+		return errors.Wrap(err, "could not parse key transfer url")
+	}
+
+	kbsUrl := url.URL{Scheme: keyUrl.Scheme, Host: keyUrl.Host}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, kbsUrl.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := svc.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "KBS %s is not reachable", kbsUrl.Host)
+	}
+	resp.Body.Close()
+	return nil
+}
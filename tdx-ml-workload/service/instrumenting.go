@@ -0,0 +1,179 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/intel/trustauthority-samples/tdxexample/ratls"
+	"github.com/intel/trustauthority-samples/tdxexample/version"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "trustauthority_demo"
+
+// Latencies of the calls out of the TD. They are observed by the service
+// itself and registered together with the request metrics.
+var (
+	kbsTransferDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "kbs_transfer_duration_seconds",
+		Help:      "Latency of KBS key transfer requests by step (nonce, transfer).",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"step"})
+
+	quoteDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "quote_generation_duration_seconds",
+		Help:      "Latency of TD quote generation.",
+		Buckets:   prometheus.DefBuckets,
+	})
+)
+
+type instrumentingMiddleware struct {
+	next     Service
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+}
+
+// InstrumentingMiddleware records request counts, error counts by type and
+// latencies per method, whether a model is loaded and the latencies of KBS
+// transfers and quote generation, and registers the metrics with reg.
+func InstrumentingMiddleware(reg prometheus.Registerer) Middleware {
+	return func(next Service) Service {
+		mw := instrumentingMiddleware{
+			next: next,
+			requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "requests_total",
+				Help:      "Number of service requests by method.",
+			}, []string{"method"}),
+			errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Namespace: metricsNamespace,
+				Name:      "request_errors_total",
+				Help:      "Number of failed service requests by method and error type.",
+			}, []string{"method", "type"}),
+			latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Namespace: metricsNamespace,
+				Name:      "request_duration_seconds",
+				Help:      "Latency of service requests by method.",
+				Buckets:   prometheus.DefBuckets,
+			}, []string{"method"}),
+		}
+
+		modelLoaded := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "model_loaded",
+			Help:      "1 if a decrypted model is loaded, 0 otherwise.",
+		}, func() float64 {
+			status, err := next.GetModelStatus(context.Background())
+			if err != nil || status.State != ModelLoaded {
+				return 0
+			}
+			return 1
+		})
+
+		reg.MustRegister(mw.requests, mw.errors, mw.latency, modelLoaded, kbsTransferDuration, quoteDuration)
+		return mw
+	}
+}
+
+func (mw instrumentingMiddleware) observe(method string, begin time.Time, err error) {
+	mw.requests.WithLabelValues(method).Inc()
+	mw.latency.WithLabelValues(method).Observe(time.Since(begin).Seconds())
+	if err != nil {
+		mw.errors.WithLabelValues(method, errorType(err)).Inc()
+	}
+}
+
+// errorType classifies errors for the request_errors_total metric
+func errorType(err error) string {
+	var validationErr *ValidationError
+	var handledErr *HandledError
+	switch {
+	case errors.As(err, &validationErr):
+		return "validation"
+	case errors.As(err, &handledErr):
+		return "handled"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	}
+	return "internal"
+}
+
+func (mw instrumentingMiddleware) GetAttestationToken(ctx context.Context) (resp *GetAttestationTokenResponse, err error) {
+	defer func(begin time.Time) { mw.observe("GetAttestationToken", begin, err) }(time.Now())
+	return mw.next.GetAttestationToken(ctx)
+}
+
+func (mw instrumentingMiddleware) GetQuote(ctx context.Context, req GetQuoteRequest) (resp *GetQuoteResponse, err error) {
+	defer func(begin time.Time) { mw.observe("GetQuote", begin, err) }(time.Now())
+	return mw.next.GetQuote(ctx, req)
+}
+
+func (mw instrumentingMiddleware) GetKey(ctx context.Context, req GetKeyRequest) (resp *GetKeyResponse, err error) {
+	defer func(begin time.Time) { mw.observe("GetKey", begin, err) }(time.Now())
+	return mw.next.GetKey(ctx, req)
+}
+
+func (mw instrumentingMiddleware) Execute(ctx context.Context, req InferRequest) (resp *InferResponse, err error) {
+	defer func(begin time.Time) { mw.observe("Execute", begin, err) }(time.Now())
+	return mw.next.Execute(ctx, req)
+}
+
+func (mw instrumentingMiddleware) Decrypt(ctx context.Context, req GetKeyResponse) (resp interface{}, err error) {
+	defer func(begin time.Time) { mw.observe("Decrypt", begin, err) }(time.Now())
+	return mw.next.Decrypt(ctx, req)
+}
+
+func (mw instrumentingMiddleware) LoadModel(ctx context.Context) (resp *LoadModelResponse, err error) {
+	defer func(begin time.Time) { mw.observe("LoadModel", begin, err) }(time.Now())
+	return mw.next.LoadModel(ctx)
+}
+
+func (mw instrumentingMiddleware) GetModelStatus(ctx context.Context) (resp *ModelStatus, err error) {
+	defer func(begin time.Time) { mw.observe("GetModelStatus", begin, err) }(time.Now())
+	return mw.next.GetModelStatus(ctx)
+}
+
+func (mw instrumentingMiddleware) CheckHealth(ctx context.Context, req HealthRequest) (resp *HealthResponse, err error) {
+	defer func(begin time.Time) { mw.observe("CheckHealth", begin, err) }(time.Now())
+	return mw.next.CheckHealth(ctx, req)
+}
+
+func (mw instrumentingMiddleware) Reset(ctx context.Context) (resp interface{}, err error) {
+	defer func(begin time.Time) { mw.observe("Reset", begin, err) }(time.Now())
+	return mw.next.Reset(ctx)
+}
+
+func (mw instrumentingMiddleware) GetVersion(ctx context.Context) (resp *version.ServiceVersion, err error) {
+	defer func(begin time.Time) { mw.observe("GetVersion", begin, err) }(time.Now())
+	return mw.next.GetVersion(ctx)
+}
+
+func (mw instrumentingMiddleware) Provision(ctx context.Context, req ProvisionRequest) (resp interface{}, err error) {
+	defer func(begin time.Time) { mw.observe("Provision", begin, err) }(time.Now())
+	return mw.next.Provision(ctx, req)
+}
+
+func (mw instrumentingMiddleware) GetProvisionStatus(ctx context.Context) (resp *ProvisionStatus, err error) {
+	defer func(begin time.Time) { mw.observe("GetProvisionStatus", begin, err) }(time.Now())
+	return mw.next.GetProvisionStatus(ctx)
+}
+
+func (mw instrumentingMiddleware) GetKeyEvidence(ctx context.Context, req KeyEvidenceRequest) (resp *ratls.Evidence, err error) {
+	defer func(begin time.Time) { mw.observe("GetKeyEvidence", begin, err) }(time.Now())
+	return mw.next.GetKeyEvidence(ctx, req)
+}
+
+func (mw instrumentingMiddleware) Reload(ctx context.Context) (resp *ReloadResponse, err error) {
+	defer func(begin time.Time) { mw.observe("Reload", begin, err) }(time.Now())
+	return mw.next.Reload(ctx)
+}
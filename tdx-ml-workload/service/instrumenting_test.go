@@ -0,0 +1,94 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestInstrumentingMiddleware(t *testing.T) {
+	next, err := NewService("", "", nil, TrustAuthorityCredentials{}, http.DefaultClient, &fakeExecutor{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := prometheus.NewRegistry()
+	svc := InstrumentingMiddleware(reg)(next)
+	mw := svc.(instrumentingMiddleware)
+	ctx := context.Background()
+
+	valid := InferRequest{
+		Pregnancies: NewFeature(2), BloodGlucose: NewFeature(120), BloodPressure: NewFeature(70), SkinThickness: NewFeature(20),
+		Insulin: NewFeature(80), BMI: NewFeature(32.5), DBF: NewFeature(0.5), Age: NewFeature(40),
+	}
+
+	// fails validation
+	svc.Execute(ctx, InferRequest{})
+	// fails in the executor without a model
+	svc.Execute(ctx, valid)
+	// no key transfer URL is configured or recorded in the model
+	svc.LoadModel(ctx)
+	svc.Decrypt(ctx, GetKeyResponse{WrappedKey: []byte{1}, WrappedSwk: []byte{2}})
+	svc.Execute(ctx, valid)
+
+	counts := []struct {
+		collector prometheus.Collector
+		want      float64
+	}{
+		{mw.requests.WithLabelValues("Execute"), 3},
+		{mw.requests.WithLabelValues("LoadModel"), 1},
+		{mw.requests.WithLabelValues("Decrypt"), 1},
+		{mw.errors.WithLabelValues("Execute", "validation"), 1},
+		{mw.errors.WithLabelValues("Execute", "internal"), 1},
+		{mw.errors.WithLabelValues("LoadModel", "handled"), 1},
+	}
+	for _, c := range counts {
+		if got := testutil.ToFloat64(c.collector); got != c.want {
+			t.Errorf("%s is %v, want %v", c.collector.(prometheus.Metric).Desc(), got, c.want)
+		}
+	}
+	// the decrypt and the last execution succeeded
+	if n := testutil.CollectAndCount(mw.errors); n != 3 {
+		t.Errorf("request_errors_total has %d series, want 3", n)
+	}
+	if n := testutil.CollectAndCount(mw.latency); n != 3 {
+		t.Errorf("request_duration_seconds has %d series, want 3", n)
+	}
+
+	expected := `
+# HELP trustauthority_demo_model_loaded 1 if a decrypted model is loaded, 0 otherwise.
+# TYPE trustauthority_demo_model_loaded gauge
+trustauthority_demo_model_loaded 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "trustauthority_demo_model_loaded"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{errors.Wrap(&ValidationError{}, "invalid request"), "validation"},
+		{errors.Wrap(&HandledError{Code: http.StatusConflict}, "could not load model"), "handled"},
+		{errors.Wrap(&url.Error{Op: "Post", URL: "https://kbs", Err: context.Canceled}, "could not transfer key"), "canceled"},
+		{errors.Wrap(context.DeadlineExceeded, "could not get token"), "timeout"},
+		{errors.New("could not execute model"), "internal"},
+	}
+
+	for _, tt := range tests {
+		if got := errorType(tt.err); got != tt.want {
+			t.Errorf("errorType(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
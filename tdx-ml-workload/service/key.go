@@ -8,12 +8,12 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
-	"net/http"
 	"net/url"
 	"time"
 
 	kbsclient "github.com/intel/kbs/v1/client"
 	"github.com/intel/trustauthority-client/go-connector"
+	"github.com/intel/trustauthority-samples/tdxexample/tracing"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
@@ -28,23 +28,17 @@ type GetKeyResponse struct {
 	WrappedSwk []byte `json:"wrapped_swk"`
 }
 
-func (t *GetKeyResponse) Headers() http.Header {
-	return corsHeaders
-}
-
-func (mw loggingMiddleware) GetKey(ctx context.Context, req GetKeyRequest) (*GetKeyResponse, error) {
-	var err error
+func (mw loggingMiddleware) GetKey(ctx context.Context, req GetKeyRequest) (resp *GetKeyResponse, err error) {
 	defer func(begin time.Time) {
-		log.Tracef("GetKey took %s since %s", time.Since(begin), begin)
-		if err != nil {
-			log.WithError(err)
-		}
+		logCall(ctx, "GetKey", begin, log.Fields{
+			"key_transfer_url":  req.KeyTransferUrl,
+			"attestation_token": redact(req.AttestationToken),
+		}, err)
 	}(time.Now())
-	resp, err := mw.next.GetKey(ctx, req)
-	return resp, err
+	return mw.next.GetKey(ctx, req)
 }
 
-func (svc service) GetKey(_ context.Context, req GetKeyRequest) (*GetKeyResponse, error) {
+func (svc service) GetKey(ctx context.Context, req GetKeyRequest) (*GetKeyResponse, error) {
 
 	var err error
 	var resp []byte
@@ -62,7 +56,11 @@ func (svc service) GetKey(_ context.Context, req GetKeyRequest) (*GetKeyResponse
 			AttestationToken: req.AttestationToken,
 		}
 	} else {
-		resp, attestationType, err = client.TransferKey()
+		spanCtx, span := tracing.Start(ctx, "kbs.TransferKey")
+		begin := time.Now()
+		resp, attestationType, err = client.TransferKey(spanCtx)
+		kbsTransferDuration.WithLabelValues("nonce").Observe(time.Since(begin).Seconds())
+		tracing.End(span, err)
 		if err != nil {
 			return nil, errors.Wrap(err, "could not get key")
 		}
@@ -73,7 +71,7 @@ func (svc service) GetKey(_ context.Context, req GetKeyRequest) (*GetKeyResponse
 			return nil, errors.New("could not unmarshal verifier nonce")
 		}
 
-		quote, userData, err := collectEvidence(base64.StdEncoding.EncodeToString(append(nonce.Val, nonce.Iat[:]...)), svc.userData)
+		quote, userData, err := collectEvidence(ctx, base64.StdEncoding.EncodeToString(append(nonce.Val, nonce.Iat[:]...)), svc.userData)
 		if err != nil {
 			return nil, errors.Wrap(err, "could not get quote")
 		}
@@ -85,7 +83,11 @@ func (svc service) GetKey(_ context.Context, req GetKeyRequest) (*GetKeyResponse
 		}
 	}
 
-	resp, err = client.TransferKeyWithEvidence(request, attestationType)
+	spanCtx, span := tracing.Start(ctx, "kbs.TransferKeyWithEvidence")
+	begin := time.Now()
+	resp, err = client.TransferKeyWithEvidence(spanCtx, request, attestationType)
+	kbsTransferDuration.WithLabelValues("transfer").Observe(time.Since(begin).Seconds())
+	tracing.End(span, err)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not transfer key")
 	}
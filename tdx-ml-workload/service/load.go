@@ -0,0 +1,160 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/intel/trustauthority-samples/modelcrypt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// errModelReset is returned by loads that were overtaken by a reset
+var errModelReset = &HandledError{
+	Code:    http.StatusConflict,
+	Message: "The model was reset while it was loading",
+}
+
+type LoadModelResponse struct {
+	Status         string `json:"status"`
+	ModelName      string `json:"model_name,omitempty"`
+	ModelVersion   string `json:"model_version,omitempty"`
+	KeyTransferUrl string `json:"key_transfer_url"`
+}
+
+func (mw loggingMiddleware) LoadModel(ctx context.Context) (resp *LoadModelResponse, err error) {
+	defer func(begin time.Time) {
+		logCall(ctx, "LoadModel", begin, nil, err)
+	}(time.Now())
+	return mw.next.LoadModel(ctx)
+}
+
+// LoadModel attests the workload, has KBS transfer the key, unwraps it and
+// decrypts the model without the wrapped key leaving the TD. The configured
+// key transfer URL is used when set, otherwise the key transfer URLs recorded
+// in the model header are tried in order if they belong to an allowed KBS.
+func (svc service) LoadModel(ctx context.Context) (*LoadModelResponse, error) {
+
+	svc.model.loadMu.RLock()
+	generation := svc.model.generation
+	svc.model.loadMu.RUnlock()
+	svc.model.loading()
+
+	resp, err := svc.loadModelFromKBS(ctx, generation)
+	if errors.Cause(err) == errModelReset {
+		return nil, err
+	}
+	if err != nil {
+		svc.model.failed(err)
+		return nil, err
+	}
+	svc.model.loaded(resp.KeyTransferUrl)
+	return resp, nil
+}
+
+func (svc service) loadModelFromKBS(ctx context.Context, generation uint64) (*LoadModelResponse, error) {
+
+	header, err := svc.executor.ModelHeader()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read model header")
+	}
+
+	keyTransferUrls := svc.keyTransferUrls(header)
+	if len(keyTransferUrls) == 0 {
+		return nil, &HandledError{
+			Code:    http.StatusConflict,
+			Message: "No key transfer URL is configured or recorded in the model for an allowed KBS",
+		}
+	}
+
+	for _, keyTransferUrl := range keyTransferUrls {
+		err = svc.loadModel(ctx, keyTransferUrl, generation)
+		if errors.Cause(err) == errModelReset {
+			return nil, err
+		}
+		if err != nil {
+			log.WithError(err).WithField("KeyTransferUrl", keyTransferUrl).Warn("Could not load model")
+			continue
+		}
+
+		resp := &LoadModelResponse{
+			Status:         "loaded",
+			KeyTransferUrl: keyTransferUrl,
+		}
+		if header != nil {
+			resp.ModelName = header.ModelName
+			resp.ModelVersion = header.ModelVersion
+		}
+		return resp, nil
+	}
+	return nil, errors.Wrap(err, "could not load model")
+}
+
+// loadModel transfers the key in passport mode, with an attestation token
+// that is evaluated against the configured Trust Authority policies. The
+// model is only decrypted if it was not reset since generation.
+func (svc service) loadModel(ctx context.Context, keyTransferUrl string, generation uint64) error {
+
+	token, err := svc.GetAttestationToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	key, err := svc.GetKey(ctx, GetKeyRequest{
+		AttestationToken: token.AttestationToken,
+		KeyTransferUrl:   keyTransferUrl,
+	})
+	if err != nil {
+		return err
+	}
+	defer zeroizeByteArray(key.WrappedKey)
+	defer zeroizeByteArray(key.WrappedSwk)
+
+	svc.model.loadMu.Lock()
+	defer svc.model.loadMu.Unlock()
+	if svc.model.generation != generation {
+		return errModelReset
+	}
+	return svc.executor.DecryptModel(key.WrappedSwk, key.WrappedKey)
+}
+
+// keyTransferUrls returns the configured key transfer URL, or those of the
+// model header that belong to an allowed KBS. The header is not
+// authenticated, so other URLs could send the workload's evidence anywhere.
+func (svc service) keyTransferUrls(header *modelcrypt.Header) []string {
+	if svc.keyTransferUrl != "" {
+		return []string{svc.keyTransferUrl}
+	}
+	if header == nil {
+		return nil
+	}
+
+	candidates := []string{header.KeyTransferURL}
+	for _, recipient := range header.Recipients {
+		candidates = append(candidates, recipient.KeyTransferURL)
+	}
+
+	var urls []string
+	for _, keyTransferUrl := range candidates {
+		if keyTransferUrl == "" {
+			continue
+		}
+		if !kbsAllowed(svc.allowedKbsUrls, keyTransferUrl) {
+			log.WithField("KeyTransferUrl", keyTransferUrl).Warn("Ignoring key transfer URL of the model, its KBS is not allowed")
+			continue
+		}
+		urls = append(urls, keyTransferUrl)
+	}
+	return urls
+}
+
+func zeroizeByteArray(bytes []byte) {
+	for i := range bytes {
+		bytes[i] = 0
+	}
+}
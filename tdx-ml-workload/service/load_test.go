@@ -0,0 +1,242 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intel/trustauthority-samples/modelcrypt"
+	"github.com/intel/trustauthority-samples/tdxexample/secret"
+	"github.com/pkg/errors"
+)
+
+// fakeExecutor stands in for the model executor. Its model is deliberately
+// not synchronized, so that the race detector reports callers that decrypt
+// or reset the model while it executes.
+type fakeExecutor struct {
+	model    []byte
+	decrypts int
+	resets   int
+}
+
+func (f *fakeExecutor) ModelHeader() (*modelcrypt.Header, error) {
+	return &modelcrypt.Header{ModelName: "diabetes"}, nil
+}
+
+func (f *fakeExecutor) DecryptModel(wrappedSwk, wrappedDek []byte) error {
+	f.model = append([]byte(nil), wrappedDek...)
+	f.decrypts++
+	return nil
+}
+
+func (f *fakeExecutor) ExecuteModel(pregnancies, glucose, bloodpressure, skinthickness, insulin, bmi, dbf, age float32) (int, error) {
+	if len(f.model) == 0 {
+		return -1, errors.New("no model")
+	}
+	return int(f.model[0]), nil
+}
+
+func (f *fakeExecutor) ResetModel() error {
+	f.model = nil
+	f.resets++
+	return nil
+}
+
+// fakeCLI puts a trustauthority-cli in PATH that prints a token
+func fakeCLI(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\necho token\n"
+	if err := os.WriteFile(filepath.Join(dir, CLI), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// newTestKBS starts a key transfer endpoint that calls transfer, if set,
+// before answering with a wrapped key
+func newTestKBS(t *testing.T, transfer func()) *httptest.Server {
+	t.Helper()
+	kbs := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if transfer != nil {
+			transfer()
+		}
+		json.NewEncoder(w).Encode(GetKeyResponse{WrappedKey: []byte{1}, WrappedSwk: []byte{2}})
+	}))
+	t.Cleanup(kbs.Close)
+	return kbs
+}
+
+func newTestService(t *testing.T, kbs *httptest.Server, executor Executor) Service {
+	t.Helper()
+	apiKey, err := secret.NewCache(secret.Static("YXBpLWtleQ=="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := TrustAuthorityCredentials{ApiUrl: "https://api.trustauthority.intel.com", ApiKey: apiKey}
+	svc, err := NewService("", kbs.URL+"/kbs/v1/keys/key-1/transfer", nil, creds, kbs.Client(), executor, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc
+}
+
+func TestExecuteWhileLoading(t *testing.T) {
+	fakeCLI(t)
+	svc := newTestService(t, newTestKBS(t, nil), &fakeExecutor{})
+	ctx := context.Background()
+	if _, err := svc.LoadModel(ctx); err != nil {
+		t.Fatalf("LoadModel: %v", err)
+	}
+
+	req := InferRequest{}
+	if err := json.Unmarshal([]byte(`{"pregnancies": 2, "blood-glucose": 120, "blood-pressure": 70,
+		"skin-thickness": 20, "insulin": 80, "bmi": 32.5, "dbf": 0.5, "age": 40}`), &req); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if _, err := svc.LoadModel(ctx); err != nil {
+					t.Errorf("LoadModel: %v", err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if resp, err := svc.Execute(ctx, req); err != nil || resp.HighRisk != 1 {
+					t.Errorf("Execute returned %+v, %v", resp, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestResetWhileLoading(t *testing.T) {
+	fakeCLI(t)
+	transferred := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	kbs := newTestKBS(t, func() {
+		// only the first transfer waits for the reset
+		once.Do(func() {
+			close(transferred)
+			<-release
+		})
+	})
+	executor := &fakeExecutor{}
+	svc := newTestService(t, kbs, executor)
+	ctx := context.Background()
+
+	loadErr := make(chan error)
+	go func() {
+		_, err := svc.LoadModel(ctx)
+		loadErr <- err
+	}()
+
+	// the reset does not wait for the key transfer of the load
+	<-transferred
+	if _, err := svc.Reset(ctx); err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	close(release)
+
+	if err := <-loadErr; errors.Cause(err) != errModelReset {
+		t.Fatalf("LoadModel returned %v, want %v", err, errModelReset)
+	}
+	if executor.decrypts != 0 {
+		t.Fatal("the model was decrypted after the reset")
+	}
+	if status, _ := svc.GetModelStatus(ctx); status.State != ModelNotLoaded {
+		t.Fatalf("model state %s, want %s", status.State, ModelNotLoaded)
+	}
+
+	// loads started after the reset install the model
+	if _, err := svc.LoadModel(ctx); err != nil {
+		t.Fatalf("LoadModel: %v", err)
+	}
+	if status, _ := svc.GetModelStatus(ctx); status.State != ModelLoaded {
+		t.Fatalf("model state %s, want %s", status.State, ModelLoaded)
+	}
+}
+
+// blockingExecutor executes the model only once release is closed
+type blockingExecutor struct {
+	*fakeExecutor
+	executing chan struct{}
+	release   chan struct{}
+}
+
+func (b blockingExecutor) ExecuteModel(pregnancies, glucose, bloodpressure, skinthickness, insulin, bmi, dbf, age float32) (int, error) {
+	close(b.executing)
+	<-b.release
+	return b.fakeExecutor.ExecuteModel(pregnancies, glucose, bloodpressure, skinthickness, insulin, bmi, dbf, age)
+}
+
+func TestResetWaitsForExecute(t *testing.T) {
+	executor := blockingExecutor{
+		fakeExecutor: &fakeExecutor{},
+		executing:    make(chan struct{}),
+		release:      make(chan struct{}),
+	}
+	svc := newTestService(t, newTestKBS(t, nil), executor)
+	ctx := context.Background()
+	if _, err := svc.Decrypt(ctx, GetKeyResponse{WrappedKey: []byte{1}, WrappedSwk: []byte{2}}); err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+
+	req := InferRequest{
+		Pregnancies: NewFeature(2), BloodGlucose: NewFeature(120), BloodPressure: NewFeature(70), SkinThickness: NewFeature(20),
+		Insulin: NewFeature(80), BMI: NewFeature(32.5), DBF: NewFeature(0.5), Age: NewFeature(40),
+	}
+	executed := make(chan error)
+	go func() {
+		resp, err := svc.Execute(ctx, req)
+		if err == nil && resp.HighRisk != 1 {
+			err = errors.Errorf("Execute returned %+v", resp)
+		}
+		executed <- err
+	}()
+	<-executor.executing
+
+	reset := make(chan error)
+	go func() {
+		_, err := svc.Reset(ctx)
+		reset <- err
+	}()
+
+	// shutdown wipes the model with Reset, it must not free the model under
+	// a prediction that was not drained
+	select {
+	case err := <-reset:
+		t.Fatalf("Reset returned %v during an execution", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(executor.release)
+	if err := <-executed; err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if err := <-reset; err != nil {
+		t.Fatalf("Reset: %v", err)
+	}
+	if executor.resets != 1 || executor.model != nil {
+		t.Fatal("Reset did not wipe the model")
+	}
+}
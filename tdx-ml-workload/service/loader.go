@@ -0,0 +1,142 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+type ModelState string
+
+const (
+	ModelNotLoaded  ModelState = "not_loaded"
+	ModelLoading    ModelState = "loading"
+	ModelLoaded     ModelState = "loaded"
+	ModelLoadFailed ModelState = "failed"
+)
+
+type ModelStatus struct {
+	State          ModelState `json:"state"`
+	Attempts       int        `json:"attempts,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	KeyTransferUrl string     `json:"key_transfer_url,omitempty"`
+	LoadedAt       *time.Time `json:"loaded_at,omitempty"`
+}
+
+// modelTracker records the progress of loading the model. It is shared by all
+// copies of the service value.
+type modelTracker struct {
+	// loadMu guards the model buffer: predictions hold the read lock, while
+	// decrypting, replacing or resetting the model holds the write lock.
+	// Loads only take it once the key has been transferred, so predictions
+	// are not blocked by the network calls to Trust Authority and KBS.
+	loadMu sync.RWMutex
+	// generation is incremented by every reset under loadMu, so that a load
+	// that started before a reset does not install the model again
+	generation uint64
+
+	mu     sync.RWMutex
+	status ModelStatus
+}
+
+func newModelTracker() *modelTracker {
+	return &modelTracker{status: ModelStatus{State: ModelNotLoaded}}
+}
+
+func (t *modelTracker) get() ModelStatus {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.status
+}
+
+// loading records a load attempt. A loaded model keeps serving while it is
+// replaced, so its state stays loaded.
+func (t *modelTracker) loading() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status.State != ModelLoaded {
+		t.status.State = ModelLoading
+	}
+	t.status.Attempts++
+}
+
+func (t *modelTracker) loaded(keyTransferUrl string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now().UTC()
+	t.status = ModelStatus{
+		State:          ModelLoaded,
+		Attempts:       t.status.Attempts,
+		KeyTransferUrl: keyTransferUrl,
+		LoadedAt:       &now,
+	}
+}
+
+// failed records the error of a load attempt. A previously loaded model is
+// still in place, so its state stays loaded.
+func (t *modelTracker) failed(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.status.State != ModelLoaded {
+		t.status.State = ModelLoadFailed
+	}
+	t.status.LastError = err.Error()
+}
+
+func (t *modelTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.status = ModelStatus{State: ModelNotLoaded}
+}
+
+func (mw loggingMiddleware) GetModelStatus(ctx context.Context) (resp *ModelStatus, err error) {
+	defer func(begin time.Time) {
+		logCall(ctx, "GetModelStatus", begin, nil, err)
+	}(time.Now())
+	return mw.next.GetModelStatus(ctx)
+}
+
+func (svc service) GetModelStatus(_ context.Context) (*ModelStatus, error) {
+	status := svc.model.get()
+	return &status, nil
+}
+
+// AutoLoadModel loads the model in the background, retrying with exponential
+// backoff from retryInterval up to maxRetryInterval until the model is loaded
+// or ctx is cancelled. The progress is reported by GetModelStatus and the
+// model readiness check.
+func AutoLoadModel(ctx context.Context, svc Service, retryInterval, maxRetryInterval time.Duration) {
+	interval := retryInterval
+	for {
+		// each attempt gets its own request ID to correlate it with KBS logs
+		requestID := uuid.NewString()
+		resp, err := svc.LoadModel(ContextWithRequestID(ctx, requestID))
+		if err == nil {
+			log.WithFields(log.Fields{
+				"ModelName":      resp.ModelName,
+				"ModelVersion":   resp.ModelVersion,
+				"KeyTransferUrl": resp.KeyTransferUrl,
+			}).Info("Loaded model on startup")
+			return
+		}
+
+		log.WithError(err).WithField("request_id", requestID).Warnf("Could not load model on startup, retrying in %s", interval)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		interval *= 2
+		if interval > maxRetryInterval {
+			interval = maxRetryInterval
+		}
+	}
+}
@@ -4,8 +4,23 @@
  */
 package service
 
+import (
+	"context"
+	"time"
+
+	"github.com/intel/trustauthority-samples/tdxexample/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// redacted replaces secrets in the request summaries that are logged
+const redacted = "[REDACTED]"
+
 type Middleware func(Service) Service
 
+// LoggingMiddleware logs one entry per service call with the method, its
+// duration, a summary of the request, the error, the request ID and the
+// authenticated caller. Failed calls are logged at error level, all others
+// at debug level.
 func LoggingMiddleware() Middleware {
 	return func(next Service) Service {
 		return loggingMiddleware{next}
@@ -15,3 +30,32 @@ func LoggingMiddleware() Middleware {
 type loggingMiddleware struct {
 	next Service
 }
+
+// logCall writes the log entry of a service call. fields summarize the
+// request and must not contain secrets, use redact for those.
+func logCall(ctx context.Context, method string, begin time.Time, fields log.Fields, err error) {
+	entry := log.WithFields(fields).WithFields(log.Fields{
+		"method":   method,
+		"duration": time.Since(begin).String(),
+	})
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		entry = entry.WithField("request_id", requestID)
+	}
+	if principal := auth.PrincipalFromContext(ctx); principal != nil {
+		entry = entry.WithField("principal", principal.Name)
+	}
+
+	if err != nil {
+		entry.WithError(err).Error("Service call failed")
+		return
+	}
+	entry.Debug("Service call completed")
+}
+
+// redact tells whether a secret was set without logging its value
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
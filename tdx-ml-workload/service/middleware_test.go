@@ -0,0 +1,136 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/intel/trustauthority-samples/tdxexample/auth"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// stubService implements the calls used by the tests, all others panic
+type stubService struct {
+	Service
+	err error
+}
+
+func (s stubService) GetKey(context.Context, GetKeyRequest) (*GetKeyResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &GetKeyResponse{}, nil
+}
+
+func (s stubService) Provision(context.Context, ProvisionRequest) (interface{}, error) {
+	return nil, s.err
+}
+
+// hookLogs captures the entries logged by the test at all levels
+func hookLogs(t *testing.T) *test.Hook {
+	t.Helper()
+	hook := test.NewGlobal()
+	level, out := log.GetLevel(), log.StandardLogger().Out
+	log.SetLevel(log.DebugLevel)
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		log.SetLevel(level)
+		log.SetOutput(out)
+		log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	})
+	return hook
+}
+
+func TestLoggingMiddlewareLevels(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantLevel log.Level
+	}{
+		{name: "succeeded", wantLevel: log.DebugLevel},
+		{name: "failed", err: errors.New("no key"), wantLevel: log.ErrorLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := hookLogs(t)
+			svc := LoggingMiddleware()(stubService{err: tt.err})
+			ctx := ContextWithRequestID(context.Background(), "request-1")
+			ctx = auth.ContextWithPrincipal(ctx, &auth.Principal{Name: "caller"})
+
+			_, err := svc.GetKey(ctx, GetKeyRequest{KeyTransferUrl: "https://kbs/keys/1/transfer"})
+			if err != tt.err {
+				t.Fatalf("GetKey returned %v, want %v", err, tt.err)
+			}
+
+			if len(hook.AllEntries()) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(hook.AllEntries()))
+			}
+			entry := hook.LastEntry()
+			if entry.Level != tt.wantLevel {
+				t.Errorf("logged at %s, want %s", entry.Level, tt.wantLevel)
+			}
+			for field, want := range map[string]interface{}{
+				"method":           "GetKey",
+				"request_id":       "request-1",
+				"principal":        "caller",
+				"key_transfer_url": "https://kbs/keys/1/transfer",
+			} {
+				if entry.Data[field] != want {
+					t.Errorf("logged %s %v, want %v", field, entry.Data[field], want)
+				}
+			}
+			if tt.err != nil && entry.Data[log.ErrorKey] != tt.err {
+				t.Errorf("logged error %v, want %v", entry.Data[log.ErrorKey], tt.err)
+			}
+		})
+	}
+}
+
+func TestLoggingMiddlewareRedactsSecrets(t *testing.T) {
+	const (
+		apiKey = "c2VjcmV0LWFwaS1rZXk="
+		token  = "eyJhbGciOiJQUzM4NCJ9.secret.token"
+	)
+	hook := hookLogs(t)
+	svc := LoggingMiddleware()(stubService{err: errors.New("failed")})
+	ctx := context.Background()
+
+	svc.Provision(ctx, ProvisionRequest{ApiKey: apiKey})
+	svc.GetKey(ctx, GetKeyRequest{AttestationToken: token})
+	svc.GetKey(ctx, GetKeyRequest{})
+
+	entries := hook.AllEntries()
+	if len(entries) != 3 {
+		t.Fatalf("logged %d entries, want 3", len(entries))
+	}
+	if entries[0].Data["api_key"] != redacted {
+		t.Errorf("logged api_key %v, want %s", entries[0].Data["api_key"], redacted)
+	}
+	if entries[1].Data["attestation_token"] != redacted {
+		t.Errorf("logged attestation_token %v, want %s", entries[1].Data["attestation_token"], redacted)
+	}
+	if entries[2].Data["attestation_token"] != "" {
+		t.Errorf("logged attestation_token %v for a request without a token", entries[2].Data["attestation_token"])
+	}
+
+	for _, entry := range entries {
+		line, err := entry.String()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, secret := range []string{apiKey, token} {
+			if strings.Contains(line, secret) || strings.Contains(fmt.Sprint(entry.Data), secret) {
+				t.Errorf("log entry contains a secret: %s", line)
+			}
+		}
+	}
+}
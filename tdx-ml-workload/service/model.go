@@ -14,74 +14,71 @@ import (
 )
 
 type InferRequest struct {
-	Pregnancies   float32 `json:"pregnancies,string"`
-	BloodGlucose  float32 `json:"blood-glucose,string"`
-	BloodPressure float32 `json:"blood-pressure,string"`
-	SkinThickness float32 `json:"skin-thickness,string"`
-	Insulin       float32 `json:"insulin,string"`
-	BMI           float32 `json:"bmi,string"`
-	Age           float32 `json:"age,string"`
-	DBF           float32 `json:"dbf,string"`
+	Pregnancies   Feature `json:"pregnancies"`
+	BloodGlucose  Feature `json:"blood-glucose"`
+	BloodPressure Feature `json:"blood-pressure"`
+	SkinThickness Feature `json:"skin-thickness"`
+	Insulin       Feature `json:"insulin"`
+	BMI           Feature `json:"bmi"`
+	Age           Feature `json:"age"`
+	DBF           Feature `json:"dbf"`
 }
 
 type InferResponse struct {
 	HighRisk int `json:"high-risk"`
 }
 
-func (t *InferResponse) Headers() http.Header {
-	return corsHeaders
-}
-
 type ModelResponse struct {
 	statusCode int
 }
 
-func (t *ModelResponse) Headers() http.Header {
-	return corsHeaders
-}
-
 func (d *ModelResponse) StatusCode() int {
 	return d.statusCode
 }
 
-func (mw loggingMiddleware) Decrypt(ctx context.Context, req GetKeyResponse) (interface{}, error) {
-	var err error
+func (mw loggingMiddleware) Decrypt(ctx context.Context, req GetKeyResponse) (resp interface{}, err error) {
 	defer func(begin time.Time) {
-		log.Tracef("Decrypt took %s since %s", time.Since(begin), begin)
-		if err != nil {
-			log.WithError(err)
-		}
+		logCall(ctx, "Decrypt", begin, log.Fields{
+			"wrapped_key_length": len(req.WrappedKey),
+			"wrapped_swk_length": len(req.WrappedSwk),
+		}, err)
 	}(time.Now())
-	resp, err := mw.next.Decrypt(ctx, req)
-	return resp, err
+	return mw.next.Decrypt(ctx, req)
 }
 
 func (svc service) Decrypt(_ context.Context, req GetKeyResponse) (interface{}, error) {
 
+	svc.model.loadMu.Lock()
+	defer svc.model.loadMu.Unlock()
+
 	err := svc.executor.DecryptModel(req.WrappedSwk, req.WrappedKey)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not decrypt model")
 	}
+	svc.model.loaded("")
 	return &ModelResponse{http.StatusNoContent}, nil
 }
 
-func (mw loggingMiddleware) Execute(ctx context.Context, req InferRequest) (*InferResponse, error) {
-	var err error
+func (mw loggingMiddleware) Execute(ctx context.Context, req InferRequest) (resp *InferResponse, err error) {
 	defer func(begin time.Time) {
-		log.Tracef("Execute took %s since %s", time.Since(begin), begin)
-		if err != nil {
-			log.WithError(err)
-		}
+		// the features are patient data and are not logged
+		logCall(ctx, "Execute", begin, nil, err)
 	}(time.Now())
-	resp, err := mw.next.Execute(ctx, req)
-	return resp, err
+	return mw.next.Execute(ctx, req)
 }
 
 func (svc service) Execute(_ context.Context, req InferRequest) (*InferResponse, error) {
 
-	res, err := svc.executor.ExecuteModel(req.Pregnancies, req.BloodGlucose,
-		req.BloodPressure, req.SkinThickness,
-		req.Insulin, req.BMI, req.DBF, req.Age)
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	svc.model.loadMu.RLock()
+	defer svc.model.loadMu.RUnlock()
+
+	res, err := svc.executor.ExecuteModel(req.Pregnancies.Value(), req.BloodGlucose.Value(),
+		req.BloodPressure.Value(), req.SkinThickness.Value(),
+		req.Insulin.Value(), req.BMI.Value(), req.DBF.Value(), req.Age.Value())
 	if err != nil {
 		return nil, errors.Wrap(err, "could not execute model")
 	}
@@ -92,23 +89,25 @@ func (svc service) Execute(_ context.Context, req InferRequest) (*InferResponse,
 	return resp, nil
 }
 
-func (mw loggingMiddleware) Reset(ctx context.Context) (interface{}, error) {
-	var err error
+func (mw loggingMiddleware) Reset(ctx context.Context) (resp interface{}, err error) {
 	defer func(begin time.Time) {
-		log.Tracef("Reset took %s since %s", time.Since(begin), begin)
-		if err != nil {
-			log.WithError(err)
-		}
+		logCall(ctx, "Reset", begin, nil, err)
 	}(time.Now())
-	resp, err := mw.next.Reset(ctx)
-	return resp, err
+	return mw.next.Reset(ctx)
 }
 
+// Reset wipes the decrypted model. It waits for running predictions, which
+// hold the read lock, and makes loads that are still running fail.
 func (svc service) Reset(_ context.Context) (interface{}, error) {
 
+	svc.model.loadMu.Lock()
+	defer svc.model.loadMu.Unlock()
+
+	svc.model.generation++
 	err := svc.executor.ResetModel()
 	if err != nil {
 		return nil, errors.Wrap(err, "could not reset model")
 	}
+	svc.model.reset()
 	return &ModelResponse{http.StatusNoContent}, nil
 }
@@ -8,33 +8,98 @@ import (
 	"context"
 	"encoding/base64"
 	"net/http"
+	"net/url"
 	"time"
 
-	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
 type ProvisionRequest struct {
 	ApiKey string `json:"api_key"`
+	// ApiUrl replaces the Trust Authority API URL, if set
+	ApiUrl string `json:"api_url,omitempty"`
+	// PolicyIds replaces the comma separated policy IDs, if set. An empty
+	// string removes them.
+	PolicyIds *string `json:"policy_ids,omitempty"`
 }
 
-func (mw loggingMiddleware) Provision(ctx context.Context, req ProvisionRequest) (interface{}, error) {
-	var err error
+// ProvisionStatus describes the Trust Authority credentials in use. The API
+// key is only identified by the first bytes of its SHA-256 digest.
+type ProvisionStatus struct {
+	Provisioned       bool       `json:"provisioned"`
+	Source            string     `json:"source,omitempty"`
+	ApiUrl            string     `json:"api_url,omitempty"`
+	PolicyIds         string     `json:"policy_ids,omitempty"`
+	ApiKeyFingerprint string     `json:"api_key_fingerprint,omitempty"`
+	ProvisionedAt     *time.Time `json:"provisioned_at,omitempty"`
+}
+
+func (mw loggingMiddleware) Provision(ctx context.Context, req ProvisionRequest) (resp interface{}, err error) {
 	defer func(begin time.Time) {
-		log.Tracef("Provision took %s since %s", time.Since(begin), begin)
-		if err != nil {
-			log.WithError(err)
+		fields := log.Fields{"api_key": redact(req.ApiKey), "api_url": req.ApiUrl}
+		if req.PolicyIds != nil {
+			fields["policy_ids"] = *req.PolicyIds
 		}
+		logCall(ctx, "Provision", begin, fields, err)
+	}(time.Now())
+	return mw.next.Provision(ctx, req)
+}
+
+func (mw loggingMiddleware) GetProvisionStatus(ctx context.Context) (resp *ProvisionStatus, err error) {
+	defer func(begin time.Time) {
+		logCall(ctx, "GetProvisionStatus", begin, nil, err)
 	}(time.Now())
-	resp, err := mw.next.Provision(ctx, req)
-	return resp, err
+	return mw.next.GetProvisionStatus(ctx)
 }
 
+// Provision stores the Trust Authority API key, and optionally the API URL
+// and policy IDs, that attestation tokens are requested with
 func (svc service) Provision(_ context.Context, req ProvisionRequest) (interface{}, error) {
-	_, err := base64.URLEncoding.DecodeString(req.ApiKey)
-	if err != nil {
-		return nil, errors.Wrap(err, "Invalid Api key, must be base64 string")
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if req.ApiUrl == "" && svc.credentials.status().ApiUrl == "" {
+		return nil, &ValidationError{Fields: []FieldError{{Field: "api_url", Message: "is required, no Trust Authority API URL is configured"}}}
+	}
+
+	if err := svc.credentials.set(req.ApiUrl, req.ApiKey, req.PolicyIds, CredentialsFromProvision); err != nil {
+		return nil, err
 	}
 
 	return &ModelResponse{http.StatusNoContent}, nil
 }
+
+func (svc service) GetProvisionStatus(_ context.Context) (*ProvisionStatus, error) {
+	return svc.credentials.status(), nil
+}
+
+func (r *ProvisionRequest) Validate() error {
+	var fieldErrors []FieldError
+	if !validApiKey(r.ApiKey) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "api_key", Message: "must be a base64 string"})
+	}
+	if r.ApiUrl != "" {
+		apiUrl, err := url.Parse(r.ApiUrl)
+		if err != nil || apiUrl.Scheme != "https" || apiUrl.Host == "" {
+			fieldErrors = append(fieldErrors, FieldError{Field: "api_url", Message: "must be a valid https url"})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return &ValidationError{Fields: fieldErrors}
+	}
+	return nil
+}
+
+// validApiKey accepts Trust Authority API keys, which are base64 strings in
+// either the standard or the URL alphabet
+func validApiKey(apiKey string) bool {
+	if apiKey == "" {
+		return false
+	}
+	if _, err := base64.StdEncoding.DecodeString(apiKey); err == nil {
+		return true
+	}
+	_, err := base64.URLEncoding.DecodeString(apiKey)
+	return err == nil
+}
@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"time"
 
+	"github.com/intel/trustauthority-samples/tdxexample/tracing"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
@@ -23,21 +24,16 @@ type GetQuoteResponse struct {
 	UserData []byte
 }
 
-func (mw loggingMiddleware) GetQuote(ctx context.Context, req GetQuoteRequest) (*GetQuoteResponse, error) {
-	var err error
+func (mw loggingMiddleware) GetQuote(ctx context.Context, req GetQuoteRequest) (resp *GetQuoteResponse, err error) {
 	defer func(begin time.Time) {
-		log.Tracef("GetQuote took %s since %s", time.Since(begin), begin)
-		if err != nil {
-			log.WithError(err)
-		}
+		logCall(ctx, "GetQuote", begin, log.Fields{"nonce_length": len(req.Nonce)}, err)
 	}(time.Now())
-	resp, err := mw.next.GetQuote(ctx, req)
-	return resp, err
+	return mw.next.GetQuote(ctx, req)
 }
 
-func (svc service) GetQuote(_ context.Context, req GetQuoteRequest) (*GetQuoteResponse, error) {
+func (svc service) GetQuote(ctx context.Context, req GetQuoteRequest) (*GetQuoteResponse, error) {
 
-	quote, userData, err := collectEvidence(base64.StdEncoding.EncodeToString(req.Nonce), svc.userData)
+	quote, userData, err := collectEvidence(ctx, base64.StdEncoding.EncodeToString(req.Nonce), svc.userData)
 	if err != nil {
 		return nil, errors.Wrap(err, "could not fetch quote")
 	}
@@ -49,13 +45,17 @@ func (svc service) GetQuote(_ context.Context, req GetQuoteRequest) (*GetQuoteRe
 	return resp, nil
 }
 
-func collectEvidence(nonce, userData string) ([]byte, []byte, error) {
+func collectEvidence(ctx context.Context, nonce, userData string) (quote []byte, runtimeData []byte, err error) {
+	_, span := tracing.Start(ctx, "collectEvidence")
+	defer func() { tracing.End(span, err) }()
 
-	out, err := exec.Command(CLI, "quote", "--nonce", nonce, "--user-data", userData).Output()
+	begin := time.Now()
+	out, err := exec.CommandContext(ctx, CLI, "quote", "--nonce", nonce, "--user-data", userData).Output()
+	quoteDuration.Observe(time.Since(begin).Seconds())
 	if err != nil {
 		return nil, nil, err
 	}
-	runtimeData, err := base64.StdEncoding.DecodeString(userData)
+	runtimeData, err = base64.StdEncoding.DecodeString(userData)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReloadResponse lists the settings that changed since the last reload by
+// the names of their environment variables
+type ReloadResponse struct {
+	// Reloaded settings are in effect
+	Reloaded []string `json:"reloaded"`
+	// RestartRequired settings only take effect after a restart
+	RestartRequired []string `json:"restart_required"`
+}
+
+// ReloadedSettings are the reloaded settings applied by the service
+type ReloadedSettings struct {
+	// PolicyIds replace the policy IDs tokens are requested with, if set
+	PolicyIds *string
+}
+
+// Reloader reads the configuration again and applies the settings that can
+// change at runtime outside of the service
+type Reloader func(ctx context.Context) (*ReloadedSettings, *ReloadResponse, error)
+
+func (mw loggingMiddleware) Reload(ctx context.Context) (resp *ReloadResponse, err error) {
+	defer func(begin time.Time) {
+		fields := log.Fields{}
+		if resp != nil {
+			fields["reloaded"] = resp.Reloaded
+			fields["restart_required"] = resp.RestartRequired
+		}
+		logCall(ctx, "Reload", begin, fields, err)
+	}(time.Now())
+	return mw.next.Reload(ctx)
+}
+
+// Reload applies the settings that can change at runtime and reports those
+// that require a restart
+func (svc service) Reload(ctx context.Context) (*ReloadResponse, error) {
+	if svc.reloader == nil {
+		return nil, &HandledError{
+			Code:    http.StatusNotImplemented,
+			Message: "Reloading the configuration is not supported",
+		}
+	}
+
+	settings, resp, err := svc.reloader(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if settings.PolicyIds != nil {
+		svc.credentials.setPolicyIds(*settings.PolicyIds)
+	}
+	return resp, nil
+}
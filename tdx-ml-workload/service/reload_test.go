@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/intel/trustauthority-samples/tdxexample/secret"
+	"github.com/pkg/errors"
+)
+
+func TestReload(t *testing.T) {
+	apiKey, err := secret.NewCache(secret.Static("YXBpLWtleQ=="))
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := TrustAuthorityCredentials{ApiUrl: "https://api.trustauthority.intel.com", ApiKey: apiKey, PolicyIds: "policy-1"}
+
+	var reloaded ReloadedSettings
+	reloader := func(context.Context) (*ReloadedSettings, *ReloadResponse, error) {
+		return &reloaded, &ReloadResponse{}, nil
+	}
+	svc, err := NewService("", "", nil, creds, http.DefaultClient, &fakeExecutor{}, reloader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policyIds := func() string {
+		status, err := svc.GetProvisionStatus(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return status.PolicyIds
+	}
+
+	// policy IDs are kept unless they were reloaded
+	if _, err := svc.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := policyIds(); got != "policy-1" {
+		t.Fatalf("policy IDs %q, want policy-1", got)
+	}
+
+	newPolicyIds := "policy-2"
+	reloaded.PolicyIds = &newPolicyIds
+	if _, err := svc.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := policyIds(); got != "policy-2" {
+		t.Fatalf("policy IDs %q, want policy-2", got)
+	}
+}
+
+func TestReloadNotSupported(t *testing.T) {
+	svc, err := NewService("", "", nil, TrustAuthorityCredentials{}, http.DefaultClient, &fakeExecutor{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Reload(context.Background())
+	var handled *HandledError
+	if !errors.As(err, &handled) || handled.Code != http.StatusNotImplemented {
+		t.Fatalf("Reload error %v, want status %d", err, http.StatusNotImplemented)
+	}
+}
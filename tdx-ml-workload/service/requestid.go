@@ -0,0 +1,49 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"net/http"
+)
+
+// RequestIDHeader carries the ID that correlates a request across the
+// workload, its logs and the KBS
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// RequestIDTransport forwards the request ID of the request context to
+// outgoing requests, so that KBS logs can be correlated with the workload's
+func RequestIDTransport(next http.RoundTripper) http.RoundTripper {
+	return requestIDTransport{next}
+}
+
+type requestIDTransport struct {
+	next http.RoundTripper
+}
+
+func (t requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := RequestIDFromContext(req.Context())
+	if requestID == "" || req.Header.Get(RequestIDHeader) != "" {
+		return t.next.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the caller's request
+	req = req.Clone(req.Context())
+	req.Header.Set(RequestIDHeader, requestID)
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/intel/trustauthority-samples/tdxexample/model"
+	"github.com/intel/trustauthority-samples/tdxexample/secret"
+	"github.com/pkg/errors"
+)
+
+// KBSKeyFunc returns a secret.KeyFunc that has the KBS of a key transfer URL
+// verify a quote of the workload and unwraps the transferred key in the TD.
+// It needs no Trust Authority credentials, so it can unseal the API key. Key
+// transfer URLs must belong to one of the KBS URLs allowedKbsUrls.
+func KBSKeyFunc(userData string, allowedKbsUrls []string, httpClient *http.Client, executor *model.ModelExecutor) secret.KeyFunc {
+	svc := service{userData: userData, httpClient: httpClient, executor: executor}
+	return func(ctx context.Context, keyTransferUrl string) ([]byte, error) {
+		if !kbsAllowed(allowedKbsUrls, keyTransferUrl) {
+			return nil, errors.Errorf("KBS of key transfer URL %s is not allowed", keyTransferUrl)
+		}
+
+		key, err := svc.GetKey(ctx, GetKeyRequest{KeyTransferUrl: keyTransferUrl})
+		if err != nil {
+			return nil, err
+		}
+		defer zeroizeByteArray(key.WrappedKey)
+		defer zeroizeByteArray(key.WrappedSwk)
+
+		return executor.UnwrapTransferredKey(key.WrappedSwk, key.WrappedKey)
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"fmt"
 	"net/http"
 
-	"github.com/intel/trustauthority-samples/tdxexample/model"
+	"github.com/intel/trustauthority-samples/modelcrypt"
+	"github.com/intel/trustauthority-samples/tdxexample/health"
+	"github.com/intel/trustauthority-samples/tdxexample/ratls"
 	"github.com/intel/trustauthority-samples/tdxexample/version"
 )
 
@@ -19,26 +21,66 @@ type Service interface {
 	GetKey(context.Context, GetKeyRequest) (*GetKeyResponse, error)
 	Execute(context.Context, InferRequest) (*InferResponse, error)
 	Decrypt(context.Context, GetKeyResponse) (interface{}, error)
+	LoadModel(context.Context) (*LoadModelResponse, error)
+	GetModelStatus(context.Context) (*ModelStatus, error)
+	CheckHealth(context.Context, HealthRequest) (*HealthResponse, error)
 	Reset(context.Context) (interface{}, error)
 	GetVersion(context.Context) (*version.ServiceVersion, error)
 	Provision(context.Context, ProvisionRequest) (interface{}, error)
+	GetProvisionStatus(context.Context) (*ProvisionStatus, error)
+	GetKeyEvidence(context.Context, KeyEvidenceRequest) (*ratls.Evidence, error)
+	Reload(context.Context) (*ReloadResponse, error)
+}
+
+// Executor decrypts and runs the model inside the TD. It is implemented by
+// model.ModelExecutor.
+type Executor interface {
+	ModelHeader() (*modelcrypt.Header, error)
+	DecryptModel(wrappedSwk, wrappedDek []byte) error
+	ExecuteModel(pregnancies, glucose, bloodpressure, skinthickness, insulin, bmi, dbf, age float32) (int, error)
+	ResetModel() error
 }
 
 type service struct {
-	userData   string
-	httpClient *http.Client
-	executor   *model.ModelExecutor
+	userData       string
+	keyTransferUrl string
+	allowedKbsUrls []string
+	credentials    *credentialStore
+	httpClient     *http.Client
+	executor       Executor
+	model          *modelTracker
+	checks         *health.Registry
+	reloader       Reloader
 }
 
-func NewService(userData string, httpClient *http.Client, executor *model.ModelExecutor) (Service, error) {
+// NewService creates the workload service. keyTransferUrl is the KBS key
+// transfer URL used to load the model and may be empty, the key transfer
+// URLs of the model are then used if they belong to one of the KBS URLs
+// allowedKbsUrls. Attestation tokens are requested with creds until other
+// credentials are provisioned, creds without API key leave the service
+// unprovisioned. Reload calls reloader, a nil reloader disables reloading.
+func NewService(userData, keyTransferUrl string, allowedKbsUrls []string, creds TrustAuthorityCredentials, httpClient *http.Client, executor Executor, reloader Reloader) (Service, error) {
+
+	credentials := newCredentialStore()
+	if creds.ApiKey != nil {
+		credentials.setCache(creds.ApiUrl, creds.ApiKey, &creds.PolicyIds, creds.ApiKey.String())
+	}
 
 	var svc Service
 	{
-		svc = service{
-			userData:   userData,
-			httpClient: httpClient,
-			executor:   executor,
+		s := service{
+			userData:       userData,
+			keyTransferUrl: keyTransferUrl,
+			allowedKbsUrls: allowedKbsUrls,
+			credentials:    credentials,
+			httpClient:     httpClient,
+			executor:       executor,
+			model:          newModelTracker(),
+			checks:         health.NewRegistry(health.DefaultTimeout),
+			reloader:       reloader,
 		}
+		s.registerHealthChecks()
+		svc = s
 	}
 
 	svc = LoggingMiddleware()(svc)
@@ -0,0 +1,109 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"context"
+
+	"github.com/intel/trustauthority-samples/tdxexample/ratls"
+	"github.com/intel/trustauthority-samples/tdxexample/tracing"
+	"github.com/intel/trustauthority-samples/tdxexample/version"
+)
+
+// TracingMiddleware starts a span for every service call, as child of the
+// span of the incoming request
+func TracingMiddleware() Middleware {
+	return func(next Service) Service {
+		return tracingMiddleware{next}
+	}
+}
+
+type tracingMiddleware struct {
+	next Service
+}
+
+func (mw tracingMiddleware) GetAttestationToken(ctx context.Context) (resp *GetAttestationTokenResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetAttestationToken")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.GetAttestationToken(ctx)
+}
+
+func (mw tracingMiddleware) GetQuote(ctx context.Context, req GetQuoteRequest) (resp *GetQuoteResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetQuote")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.GetQuote(ctx, req)
+}
+
+func (mw tracingMiddleware) GetKey(ctx context.Context, req GetKeyRequest) (resp *GetKeyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetKey")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.GetKey(ctx, req)
+}
+
+func (mw tracingMiddleware) Execute(ctx context.Context, req InferRequest) (resp *InferResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Execute")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.Execute(ctx, req)
+}
+
+func (mw tracingMiddleware) Decrypt(ctx context.Context, req GetKeyResponse) (resp interface{}, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Decrypt")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.Decrypt(ctx, req)
+}
+
+func (mw tracingMiddleware) LoadModel(ctx context.Context) (resp *LoadModelResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.LoadModel")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.LoadModel(ctx)
+}
+
+func (mw tracingMiddleware) GetModelStatus(ctx context.Context) (resp *ModelStatus, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetModelStatus")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.GetModelStatus(ctx)
+}
+
+func (mw tracingMiddleware) CheckHealth(ctx context.Context, req HealthRequest) (resp *HealthResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.CheckHealth")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.CheckHealth(ctx, req)
+}
+
+func (mw tracingMiddleware) Reset(ctx context.Context) (resp interface{}, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Reset")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.Reset(ctx)
+}
+
+func (mw tracingMiddleware) GetVersion(ctx context.Context) (resp *version.ServiceVersion, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetVersion")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.GetVersion(ctx)
+}
+
+func (mw tracingMiddleware) Provision(ctx context.Context, req ProvisionRequest) (resp interface{}, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Provision")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.Provision(ctx, req)
+}
+
+func (mw tracingMiddleware) GetProvisionStatus(ctx context.Context) (resp *ProvisionStatus, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetProvisionStatus")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.GetProvisionStatus(ctx)
+}
+
+func (mw tracingMiddleware) GetKeyEvidence(ctx context.Context, req KeyEvidenceRequest) (resp *ratls.Evidence, err error) {
+	ctx, span := tracing.Start(ctx, "Service.GetKeyEvidence")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.GetKeyEvidence(ctx, req)
+}
+
+func (mw tracingMiddleware) Reload(ctx context.Context) (resp *ReloadResponse, err error) {
+	ctx, span := tracing.Start(ctx, "Service.Reload")
+	defer func() { tracing.End(span, err) }()
+	return mw.next.Reload(ctx)
+}
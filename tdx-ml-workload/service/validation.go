@@ -0,0 +1,147 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Feature is a single model input. It accepts both JSON numbers and numeric
+// strings so that clients sending "34" as well as 34 are supported.
+type Feature struct {
+	value   float32
+	present bool
+	invalid bool
+}
+
+func NewFeature(value float32) Feature {
+	return Feature{value: value, present: true}
+}
+
+func (f Feature) Value() float32 {
+	return f.value
+}
+
+func (f *Feature) UnmarshalJSON(data []byte) error {
+	*f = Feature{}
+
+	raw := strings.TrimSpace(string(data))
+	if raw == "null" {
+		return nil
+	}
+	f.present = true
+
+	if strings.HasPrefix(raw, `"`) {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		raw = strings.TrimSpace(raw)
+	}
+
+	value, err := strconv.ParseFloat(raw, 32)
+	if err != nil {
+		// out of range values are kept as +/-Inf and rejected by the finite check
+		if numErr, ok := err.(*strconv.NumError); !ok || numErr.Err != strconv.ErrRange {
+			f.invalid = true
+			return nil
+		}
+	}
+	f.value = float32(value)
+	return nil
+}
+
+func (f Feature) MarshalJSON() ([]byte, error) {
+	if !f.present {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.value)
+}
+
+// featureRule describes the accepted values of one InferRequest field
+type featureRule struct {
+	name     string
+	required bool
+	min      float64
+	max      float64
+	field    func(*InferRequest) Feature
+}
+
+// inferRequestSchema bounds each feature by the normalization range the model
+// was trained with (see normalizeInput in model/lin_reg.cpp)
+var inferRequestSchema = []featureRule{
+	{name: "pregnancies", required: true, min: 0, max: 28, field: func(r *InferRequest) Feature { return r.Pregnancies }},
+	{name: "blood-glucose", required: true, min: 0, max: 200, field: func(r *InferRequest) Feature { return r.BloodGlucose }},
+	{name: "blood-pressure", required: true, min: 0, max: 125, field: func(r *InferRequest) Feature { return r.BloodPressure }},
+	{name: "skin-thickness", required: true, min: 0, max: 100, field: func(r *InferRequest) Feature { return r.SkinThickness }},
+	{name: "insulin", required: true, min: 0, max: 850, field: func(r *InferRequest) Feature { return r.Insulin }},
+	{name: "bmi", required: true, min: 0, max: 68, field: func(r *InferRequest) Feature { return r.BMI }},
+	{name: "dbf", required: true, min: 0, max: 2.45, field: func(r *InferRequest) Feature { return r.DBF }},
+	{name: "age", required: true, min: 0, max: 100, field: func(r *InferRequest) Feature { return r.Age }},
+}
+
+// Validate checks every feature of the request against inferRequestSchema and
+// reports all violations at once
+func (r *InferRequest) Validate() error {
+	var fieldErrors []FieldError
+	for _, rule := range inferRequestSchema {
+		if msg := rule.check(rule.field(r)); msg != "" {
+			fieldErrors = append(fieldErrors, FieldError{Field: rule.name, Message: msg})
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return &ValidationError{Fields: fieldErrors}
+	}
+	return nil
+}
+
+func (rule featureRule) check(f Feature) string {
+	switch {
+	case !f.present:
+		if rule.required {
+			return "is required"
+		}
+		return ""
+	case f.invalid:
+		return "must be a number"
+	}
+
+	value := float64(f.value)
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return "must be a finite number"
+	}
+	// the features are float32, so the bounds are compared at that precision
+	// for values like 2.45 to be accepted
+	if value < float64(float32(rule.min)) || value > float64(float32(rule.max)) {
+		return fmt.Sprintf("must be between %g and %g", rule.min, rule.max)
+	}
+	return ""
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, fmt.Sprintf("%s %s", f.Field, f.Message))
+	}
+	return "Invalid request: " + strings.Join(msgs, ", ")
+}
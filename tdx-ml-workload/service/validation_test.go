@@ -0,0 +1,133 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// validRequest is an InferRequest with every feature in range, fields are
+// replaced by the tests
+func validRequest() map[string]interface{} {
+	return map[string]interface{}{
+		"pregnancies":    2,
+		"blood-glucose":  120,
+		"blood-pressure": 70,
+		"skin-thickness": 20,
+		"insulin":        80,
+		"bmi":            32.5,
+		"dbf":            0.5,
+		"age":            40,
+	}
+}
+
+func TestFeatureUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		json        string
+		want        float32
+		wantPresent bool
+		wantInvalid bool
+	}{
+		{json: `34`, want: 34, wantPresent: true},
+		{json: `0.25`, want: 0.25, wantPresent: true},
+		{json: `"34"`, want: 34, wantPresent: true},
+		{json: `" 2.5 "`, want: 2.5, wantPresent: true},
+		{json: `null`},
+		{json: `"abc"`, wantPresent: true, wantInvalid: true},
+		{json: `""`, wantPresent: true, wantInvalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.json, func(t *testing.T) {
+			var f Feature
+			if err := json.Unmarshal([]byte(tt.json), &f); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if f.Value() != tt.want || f.present != tt.wantPresent || f.invalid != tt.wantInvalid {
+				t.Fatalf("Unmarshal %s = %+v", tt.json, f)
+			}
+		})
+	}
+}
+
+func TestInferRequestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     map[string]interface{}
+		remove  []string
+		wantErr []FieldError
+	}{
+		{name: "valid"},
+		{name: "numeric strings", set: map[string]interface{}{"age": "40", "bmi": "32.5"}},
+		{name: "bounds", set: map[string]interface{}{"pregnancies": 0, "dbf": 2.45, "age": 100}},
+		{name: "below minimum", set: map[string]interface{}{"insulin": -1}, wantErr: []FieldError{
+			{Field: "insulin", Message: "must be between 0 and 850"},
+		}},
+		{name: "above maximum", set: map[string]interface{}{"blood-glucose": 201, "age": "101"}, wantErr: []FieldError{
+			{Field: "blood-glucose", Message: "must be between 0 and 200"},
+			{Field: "age", Message: "must be between 0 and 100"},
+		}},
+		{name: "missing fields", remove: []string{"bmi", "pregnancies"}, wantErr: []FieldError{
+			{Field: "pregnancies", Message: "is required"},
+			{Field: "bmi", Message: "is required"},
+		}},
+		{name: "null field", set: map[string]interface{}{"dbf": nil}, wantErr: []FieldError{
+			{Field: "dbf", Message: "is required"},
+		}},
+		{name: "not a number", set: map[string]interface{}{"skin-thickness": "thin"}, wantErr: []FieldError{
+			{Field: "skin-thickness", Message: "must be a number"},
+		}},
+		{name: "NaN and Inf", set: map[string]interface{}{"bmi": "NaN", "age": "Inf", "insulin": "-Inf"}, wantErr: []FieldError{
+			{Field: "insulin", Message: "must be a finite number"},
+			{Field: "bmi", Message: "must be a finite number"},
+			{Field: "age", Message: "must be a finite number"},
+		}},
+		{name: "out of float32 range", set: map[string]interface{}{"blood-pressure": 1e40}, wantErr: []FieldError{
+			{Field: "blood-pressure", Message: "must be a finite number"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := validRequest()
+			for name, value := range tt.set {
+				fields[name] = value
+			}
+			for _, name := range tt.remove {
+				delete(fields, name)
+			}
+			body, err := json.Marshal(fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var req InferRequest
+			if err := json.Unmarshal(body, &req); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			err = req.Validate()
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+
+			validationError, ok := err.(*ValidationError)
+			if !ok {
+				t.Fatalf("Validate returned %v, want a ValidationError", err)
+			}
+			if validationError.StatusCode() != http.StatusBadRequest {
+				t.Errorf("status code %d, want %d", validationError.StatusCode(), http.StatusBadRequest)
+			}
+			if !reflect.DeepEqual(validationError.Fields, tt.wantErr) {
+				t.Fatalf("Validate reported %v, want %v", validationError.Fields, tt.wantErr)
+			}
+		})
+	}
+}
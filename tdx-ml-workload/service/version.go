@@ -9,19 +9,13 @@ import (
 	"time"
 
 	"github.com/intel/trustauthority-samples/tdxexample/version"
-	log "github.com/sirupsen/logrus"
 )
 
-func (mw loggingMiddleware) GetVersion(ctx context.Context) (*version.ServiceVersion, error) {
-	var err error
+func (mw loggingMiddleware) GetVersion(ctx context.Context) (resp *version.ServiceVersion, err error) {
 	defer func(begin time.Time) {
-		log.Infof("GetVersion took %s since %s", time.Since(begin), begin)
-		if err != nil {
-			log.WithError(err)
-		}
+		logCall(ctx, "GetVersion", begin, nil, err)
 	}(time.Now())
-	resp, err := mw.next.GetVersion(ctx)
-	return resp, err
+	return mw.next.GetVersion(ctx)
 }
 
 func (svc service) GetVersion(ctx context.Context) (*version.ServiceVersion, error) {
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// shutdown stops the servers from accepting connections and waits up to
+// timeout for in-flight requests, closing the remaining connections after
+// that. It then wipes the decrypted model and zeroizes the workload's private
+// key, also if draining timed out.
+func shutdown(timeout time.Duration, svc service.Service, privKey *rsa.PrivateKey, servers ...*http.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	drainErrs := make([]error, len(servers))
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server *http.Server) {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				drainErrs[i] = errors.Wrapf(err, "Failed to drain requests of server %s", server.Addr)
+				// closing the connections cancels the contexts of the
+				// remaining requests, which aborts their KBS calls
+				server.Close()
+			}
+		}(i, server)
+	}
+	wg.Wait()
+
+	var result error
+	for _, err := range drainErrs {
+		if err != nil {
+			log.WithError(err).Error("Requests were not drained in time")
+			result = err
+		}
+	}
+
+	// Reset waits for predictions of requests that were not drained before it
+	// wipes the model, and makes loads that are still running fail
+	if _, err := svc.Reset(context.Background()); err != nil {
+		log.WithError(err).Error("Failed to wipe the model")
+		result = err
+	}
+
+	ZeroizeRSAPrivateKey(privKey)
+	log.Info("Wiped the model and the private key")
+	return result
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+)
+
+// shutdownRecorder records the steps of a shutdown in their order
+type shutdownRecorder struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (r *shutdownRecorder) record(step string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, step)
+}
+
+func (r *shutdownRecorder) recorded() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.steps...)
+}
+
+// resetService records when the model is wiped and whether the private key
+// was still intact at that time
+type resetService struct {
+	service.Service
+	recorder *shutdownRecorder
+	privKey  *rsa.PrivateKey
+}
+
+func (s resetService) Reset(context.Context) (interface{}, error) {
+	if s.privKey.D.Sign() == 0 {
+		s.recorder.record("reset after key zeroized")
+	} else {
+		s.recorder.record("reset")
+	}
+	return nil, nil
+}
+
+// startTestServer serves handler on a loopback port and returns the server
+// and its URL
+func startTestServer(t *testing.T, handler http.Handler) (*http.Server, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &http.Server{Addr: listener.Addr().String(), Handler: handler}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return server, "http://" + listener.Addr().String()
+}
+
+func TestShutdownOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		requestTime time.Duration
+		wantErr     bool
+	}{
+		{name: "drained", timeout: 5 * time.Second, requestTime: 200 * time.Millisecond},
+		{name: "drain timed out", timeout: 100 * time.Millisecond, requestTime: time.Minute, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the words of D must be overwritten, not only replaced
+			words := privKey.D.Bits()
+			recorder := &shutdownRecorder{}
+			svc := resetService{recorder: recorder, privKey: privKey}
+
+			started := make(chan struct{})
+			server, url := startTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(tt.requestTime):
+					recorder.record("request completed")
+				case <-r.Context().Done():
+					recorder.record("request canceled")
+				}
+			}))
+			idle, _ := startTestServer(t, http.NotFoundHandler())
+
+			go http.Get(url)
+			<-started
+
+			err = shutdown(tt.timeout, svc, privKey, server, idle)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("shutdown returned %v, want error %v", err, tt.wantErr)
+			}
+
+			// closing the connections cancels the request asynchronously
+			deadline := time.Now().Add(time.Second)
+			for len(recorder.recorded()) < 2 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			steps := recorder.recorded()
+			if len(steps) != 2 {
+				t.Fatalf("shutdown steps %v, want the request and the reset", steps)
+			}
+			if tt.wantErr {
+				// the request is only canceled after draining gave up
+				if !contains(steps, "reset") || !contains(steps, "request canceled") {
+					t.Fatalf("shutdown steps %v, want the request canceled and the model reset", steps)
+				}
+			} else if steps[0] != "request completed" || steps[1] != "reset" {
+				t.Fatalf("shutdown steps %v, want the request drained before the model reset", steps)
+			}
+			if privKey.D.Sign() != 0 {
+				t.Fatal("shutdown did not zeroize the private key")
+			}
+			for _, word := range words {
+				if word != 0 {
+					t.Fatal("shutdown left the private key in memory")
+				}
+			}
+			for _, prime := range privKey.Primes {
+				if prime.Sign() != 0 {
+					t.Fatal("shutdown did not zeroize the primes of the private key")
+				}
+			}
+		})
+	}
+}
+
+func contains(steps []string, step string) bool {
+	for _, s := range steps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
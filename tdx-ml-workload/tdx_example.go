@@ -5,29 +5,26 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/tls"
-	"crypto/x509"
-	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/binary"
-	"encoding/pem"
 	"fmt"
-	"math/big"
-	"net"
 	"net/http"
 	"os"
-	"path/filepath"
-	"strings"
+	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/intel/trustauthority-samples/tdxexample/model"
 	"github.com/intel/trustauthority-samples/tdxexample/service"
+	"github.com/intel/trustauthority-samples/tdxexample/tracing"
 	httpTransport "github.com/intel/trustauthority-samples/tdxexample/transport/http"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 const (
@@ -40,7 +37,16 @@ const (
 )
 
 func main() {
-	conf, err := configure()
+	configPath, args, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+		os.Exit(exitUsage)
+	}
+	if len(args) > 0 {
+		os.Exit(runCommand(configPath, args))
+	}
+
+	conf, err := configure(configPath)
 	if err != nil {
 		panic(err)
 	}
@@ -50,15 +56,31 @@ func main() {
 		panic(err)
 	}
 
+	// SIGTERM and SIGINT start the graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	// Initialize tracing, the trace context of incoming requests is
+	// propagated to the KBS even when no exporter is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), conf.TracesExporter)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			log.WithError(err).Error("Failed to flush traces")
+		}
+	}()
+
 	// Initialize http client
 	tlsConfig := &tls.Config{}
 	if conf.SkipTLSVerification {
 		tlsConfig.InsecureSkipVerify = true
 	}
 	httpClient := &http.Client{
-		Transport: &http.Transport{
+		Transport: otelhttp.NewTransport(service.RequestIDTransport(&http.Transport{
 			TLSClientConfig: tlsConfig,
-		},
+		})),
 	}
 
 	// Initialize user data
@@ -69,16 +91,66 @@ func main() {
 	userData := base64.StdEncoding.EncodeToString(pubBytes)
 
 	// Initialize Model Executor
-	modelExecutor := model.NewModelExecutor("/etc/model.enc", privKey)
+	modelExecutor := model.NewModelExecutor(conf.ModelPath, privKey)
+
+	// Reload the reloadable settings on SIGHUP or by the reload endpoint, the
+	// reloader keeps the API key digest before the key is removed from conf
+	cors := httpTransport.NewCORSPolicy(conf.corsOptions())
+	reloader := newConfigReloader(configPath, conf, cors)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	// Resolve the Trust Authority API key on first use, sealed API keys are
+	// unwrapped with a key the KBS transfers after verifying a quote
+	apiKey, err := newApiKeyCache(conf, service.KBSKeyFunc(userData, conf.allowedKbsUrls(), httpClient, modelExecutor))
+	if err != nil {
+		panic(err)
+	}
+
+	reloader.setSecrets(apiKey)
 
 	// Initialize the Service
-	svc, err := service.NewService(userData, httpClient, modelExecutor)
+	svc, err := service.NewService(userData, conf.KeyTransferUrl, conf.allowedKbsUrls(), service.TrustAuthorityCredentials{
+		ApiUrl:    conf.TrustAuthorityUrl,
+		ApiKey:    apiKey,
+		PolicyIds: conf.PolicyIds,
+	}, httpClient, modelExecutor, reloader.Reload)
+	if err != nil {
+		panic(err)
+	}
+
+	// Trace service calls as children of the incoming request span
+	svc = service.TracingMiddleware()(svc)
+
+	// Record service metrics and serve them on a separate listener, or on
+	// the admin listener if one is configured
+	var servers []*http.Server
+	metricsRegistry := newMetricsRegistry()
+	svc = service.InstrumentingMiddleware(metricsRegistry)(svc)
+	if conf.MetricsPort != 0 && conf.AdminListenAddress == "" {
+		servers = append(servers, startMetricsServer(conf.MetricsHost, conf.MetricsPort, time.Duration(conf.HTTPReadHdrTimeout)*time.Second, metricsRegistry))
+	}
+
+	// Load the model in the background, /readyz reports ready once it is loaded
+	if conf.AutoLoadModel {
+		go service.AutoLoadModel(ctx, svc,
+			time.Duration(conf.AutoLoadRetryInterval)*time.Second,
+			time.Duration(conf.AutoLoadMaxRetryInterval)*time.Second)
+	}
+
+	// Authenticate REST API callers
+	authenticator, err := newAuthenticator(context.Background(), conf)
 	if err != nil {
 		panic(err)
 	}
 
-	// Associate the service to rest endpoints/http
-	httpHandlers, err := httpTransport.NewHTTPHandler(svc)
+	// Associate the service to rest endpoints/http, the operational routes
+	// are moved to the admin listener if one is configured
+	routes := httpTransport.RoutesAll
+	if conf.AdminListenAddress != "" {
+		routes = httpTransport.RoutesPublic
+	}
+	httpHandlers, err := httpTransport.NewHTTPHandler(svc, routes, authenticator, cors)
 	if err != nil {
 		panic(err)
 	}
@@ -96,24 +168,80 @@ func main() {
 		},
 	}
 
-	// TLS certificate is passed
-	if _, err := os.Stat(DefaultTLSCertPath); os.IsNotExist(err) {
-		// TLS certificate and key does not exist, so creating the cert and key
-		err = generateTLSKeyandCert(DefaultTLSCertPath, DefaultTLSKeyPath, conf.SanList)
+	// Verify client certificates, if presented, for mTLS authentication
+	if conf.AuthClientCAPath != "" {
+		clientCAs, err := loadClientCAs(conf.AuthClientCAPath)
 		if err != nil {
 			panic(err)
 		}
+		httpServer.TLSConfig.ClientCAs = clientCAs
+		httpServer.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
 	}
-	log.Debugf("Starting HTTPS server with TLS cert: %s", DefaultTLSCertPath)
 
-	if err := httpServer.ListenAndServeTLS(DefaultTLSCertPath, DefaultTLSKeyPath); err != nil {
+	// Serve a certificate carrying attestation evidence for a key generated
+	// in the TD, or the configured TLS certificate, or a self-signed one if
+	// it does not exist, and reload it when it changes
+	var certs certificateSource
+	if conf.TLSEvidence != attestationEvidenceNone {
+		certs, err = newAttestedCertSource(ctx, svc, conf.SanList, conf.TLSEvidence == attestationEvidenceToken)
+		log.Debug("Starting HTTPS server with attested TLS cert")
+	} else {
+		certs, err = newCertReloader(conf.TLSCertPath, conf.TLSKeyPath, conf.TLSCAPath, conf.SanList)
+		log.Debugf("Starting HTTPS server with TLS cert: %s", conf.TLSCertPath)
+	}
+	if err != nil {
 		panic(err)
 	}
+	httpServer.TLSConfig.GetCertificate = certs.GetCertificate
+	go certs.watch(ctx)
+	reloader.addCertificates(certs)
+
+	// Serve the operational routes and the metrics on the admin listener,
+	// which browsers are not expected to call
+	if conf.AdminListenAddress != "" {
+		adminHandler, err := httpTransport.NewHTTPHandler(svc, httpTransport.RoutesAdmin, newAdminAuthenticator(conf), httpTransport.NewCORSPolicy(httpTransport.CORSOptions{}))
+		if err != nil {
+			panic(err)
+		}
+		adminServer, adminCerts, err := startAdminServer(conf, adminHandler, metricsRegistry)
+		if err != nil {
+			panic(err)
+		}
+		servers = append(servers, adminServer)
+		if adminCerts != nil {
+			go adminCerts.watch(ctx)
+			reloader.addCertificates(adminCerts)
+		}
+	}
+
+	go reloadOnSignal(ctx, hup, svc)
+
+	servers = append(servers, httpServer)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServeTLS("", "")
+	}()
+
+	var serverErr error
+	select {
+	case serverErr = <-serveErr:
+		log.WithError(serverErr).Error("HTTPS server stopped")
+	case <-ctx.Done():
+		log.Info("Shutting down")
+	}
+	stop()
+
+	if err := shutdown(time.Duration(conf.ShutdownTimeout)*time.Second, svc, privKey, servers...); err != nil {
+		log.WithError(err).Error("Shutdown was not clean")
+	}
+	if serverErr != nil {
+		panic(serverErr)
+	}
 }
 
 func generateKeyPair() (*rsa.PrivateKey, []byte, error) {
+	// the key is used for the lifetime of the workload and zeroized on shutdown
 	keyPair, err := rsa.GenerateKey(rand.Reader, DefaultKeyLength)
-	defer ZeroizeRSAPrivateKey(keyPair)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "error while generating RSA key pair")
 	}
@@ -125,82 +253,3 @@ func generateKeyPair() (*rsa.PrivateKey, []byte, error) {
 	pubBytes = append(pubBytes, pub.N.Bytes()...)
 	return keyPair, pubBytes, nil
 }
-
-func generateTLSKeyandCert(TLSCertPath, TLSKeyPath, TlsSanList string) error {
-	key, err := rsa.GenerateKey(rand.Reader, DefaultKeyLength)
-	defer ZeroizeRSAPrivateKey(key)
-	if err != nil {
-		return errors.Wrap(err, "error while generating RSA key pair")
-	}
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
-	if err != nil {
-		return errors.Wrap(err, "Failed to create serial number")
-	}
-	template := x509.Certificate{
-		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			Organization: []string{"Acme Co"},
-		},
-
-		NotBefore:             time.Now(),
-		NotAfter:              time.Now().AddDate(0, 0, ValidityDays),
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
-		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
-		BasicConstraintsValid: true,
-	}
-
-	// add the san list for tls certificate
-	hosts := strings.Split(TlsSanList, ",")
-	for _, h := range hosts {
-		h = strings.TrimSpace(h)
-		if ip := net.ParseIP(h); ip != nil {
-			template.IPAddresses = append(template.IPAddresses, ip)
-		} else {
-			template.DNSNames = append(template.DNSNames, h)
-		}
-	}
-
-	// store key and cert to file
-	selfSignCert, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
-	defer ZeroizeByteArray(selfSignCert)
-	if err != nil {
-		return errors.Wrap(err, "Failed to create certificate")
-	}
-	tlsCertPath := filepath.Clean(TLSCertPath)
-	certOut, err := os.OpenFile(tlsCertPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, 0600)
-	if err != nil {
-		return fmt.Errorf("could not open %s file for writing: %v", tlsCertPath, err)
-	}
-	defer func() {
-		derr := certOut.Close()
-		if derr != nil {
-			log.WithError(derr).Error("Error closing Cert file")
-		}
-	}()
-	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: selfSignCert}); err != nil {
-		return fmt.Errorf("could not pem encode cert: %v", err)
-	}
-
-	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
-	defer ZeroizeByteArray(keyDer)
-	if err != nil {
-		return errors.Wrap(err, "Unable to marshal private key")
-	}
-	tlsKeyPath := filepath.Clean(TLSKeyPath)
-	keyOut, err := os.OpenFile(tlsKeyPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, 0600) // open file with restricted permissions
-	if err != nil {
-		return fmt.Errorf("could not open %s file for writing: %v", tlsKeyPath, err)
-	}
-	defer func() {
-		derr := keyOut.Close()
-		if derr != nil {
-			log.WithError(derr).Error("Error closing Key file")
-		}
-	}()
-	if err := pem.Encode(keyOut, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDer}); err != nil {
-		return fmt.Errorf("could not pem encode the private key: %v", err)
-	}
-
-	return nil
-}
@@ -0,0 +1,398 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// selfSignedOrganization marks certificates generated by the workload,
+	// only those are regenerated when they expire
+	selfSignedOrganization = "Intel Trust Authority Demo"
+	// legacySelfSignedOrganization marks certificates generated by earlier
+	// versions
+	legacySelfSignedOrganization = "Acme Co"
+
+	certCheckInterval    = 30 * time.Second
+	certExpiryWarnPeriod = 30 * 24 * time.Hour
+	// expiry warnings are repeated daily, and after each reload
+	certExpiryLogInterval = 24 * time.Hour
+)
+
+// certReloader serves the TLS certificate from the configured files and
+// reloads it when they change. Expired certificates generated by the
+// workload are replaced with new ones.
+type certReloader struct {
+	certPath string
+	keyPath  string
+	caPath   string
+	sanList  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	leaf     *x509.Certificate
+	modTimes []time.Time
+
+	// expiryLogged is only accessed by check
+	expiryLogged time.Time
+}
+
+// newCertReloader loads the certificate, generating a self-signed one if the
+// certificate file does not exist
+func newCertReloader(certPath, keyPath, caPath, sanList string) (*certReloader, error) {
+	r := &certReloader{certPath: certPath, keyPath: keyPath, caPath: caPath, sanList: sanList}
+
+	if _, err := os.Stat(certPath); os.IsNotExist(err) {
+		log.Infof("TLS certificate %s does not exist, generating a self-signed certificate", certPath)
+		if err := generateTLSKeyandCert(certPath, keyPath, sanList); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// reload loads the certificate, key and CA files and serves them. The
+// current certificate is kept if they cannot be loaded.
+func (r *certReloader) reload() error {
+	install, err := r.load(context.Background())
+	if err != nil {
+		return err
+	}
+	install()
+	return nil
+}
+
+// load loads the certificate, key and CA files and returns a function that
+// serves them
+func (r *certReloader) load(context.Context) (func(), error) {
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to load TLS certificate and key")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to parse TLS certificate")
+	}
+	cert.Leaf = leaf
+
+	if r.caPath != "" {
+		chain, err := readCertificateChain(r.caPath)
+		if err != nil {
+			return nil, err
+		}
+		cert.Certificate = append(cert.Certificate, chain...)
+	}
+
+	return func() {
+		r.mu.Lock()
+		r.cert = &cert
+		r.leaf = leaf
+		r.modTimes = modTimes
+		r.mu.Unlock()
+
+		log.WithFields(log.Fields{
+			"Subject":  leaf.Subject.String(),
+			"Issuer":   leaf.Issuer.String(),
+			"NotAfter": leaf.NotAfter,
+		}).Info("Loaded TLS certificate")
+	}, nil
+}
+
+// watch checks the certificate files periodically until ctx is done. It
+// reloads the certificate when a file changed, warns when it is about to
+// expire and regenerates expired self-signed certificates.
+func (r *certReloader) watch(ctx context.Context) {
+	ticker := time.NewTicker(certCheckInterval)
+	defer ticker.Stop()
+
+	r.check()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.check()
+		}
+	}
+}
+
+func (r *certReloader) check() {
+	if r.changed() {
+		if err := r.reload(); err != nil {
+			log.WithError(err).Error("Failed to reload TLS certificate, serving the previous one")
+		} else {
+			r.expiryLogged = time.Time{}
+		}
+	}
+
+	r.mu.RLock()
+	leaf := r.leaf
+	r.mu.RUnlock()
+
+	remaining := time.Until(leaf.NotAfter)
+	switch {
+	case remaining <= 0 && isGeneratedCertificate(leaf):
+		log.Warn("Self-signed TLS certificate expired, generating a new one")
+		if err := generateTLSKeyandCert(r.certPath, r.keyPath, r.sanList); err != nil {
+			log.WithError(err).Error("Failed to generate TLS certificate")
+			return
+		}
+		if err := r.reload(); err != nil {
+			log.WithError(err).Error("Failed to load the generated TLS certificate")
+		}
+	case remaining <= 0 && time.Since(r.expiryLogged) >= certExpiryLogInterval:
+		log.Errorf("TLS certificate %s expired on %s", r.certPath, leaf.NotAfter)
+		r.expiryLogged = time.Now()
+	case remaining > 0 && remaining < certExpiryWarnPeriod && time.Since(r.expiryLogged) >= certExpiryLogInterval:
+		log.Warnf("TLS certificate %s expires on %s", r.certPath, leaf.NotAfter)
+		r.expiryLogged = time.Now()
+	}
+}
+
+// changed reports whether a file was modified since the last reload
+func (r *certReloader) changed() bool {
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		log.WithError(err).Warn("Failed to check TLS certificate files")
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := range modTimes {
+		if !modTimes[i].Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *certReloader) fileModTimes() ([]time.Time, error) {
+	paths := []string{r.certPath, r.keyPath}
+	if r.caPath != "" {
+		paths = append(paths, r.caPath)
+	}
+
+	modTimes := make([]time.Time, len(paths))
+	for i, path := range paths {
+		// Stat follows symlinks, so swapped secret mounts are detected
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Failed to stat %s", path)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// readCertificateChain reads the PEM encoded CA certificates that are sent
+// along with the certificate
+func readCertificateChain(path string) ([][]byte, error) {
+	pemBytes, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to read TLS CA certificates")
+	}
+
+	var chain [][]byte
+	for {
+		var block *pem.Block
+		block, pemBytes = pem.Decode(pemBytes)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, errors.Wrap(err, "Failed to parse TLS CA certificate")
+		}
+		chain = append(chain, block.Bytes)
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.Errorf("No certificates found in %s", path)
+	}
+	return chain, nil
+}
+
+// isGeneratedCertificate reports whether cert is self-signed by the workload
+func isGeneratedCertificate(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawIssuer, cert.RawSubject) ||
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) != nil {
+		return false
+	}
+	for _, org := range cert.Subject.Organization {
+		if org == selfSignedOrganization || org == legacySelfSignedOrganization {
+			return true
+		}
+	}
+	return false
+}
+
+// certificateSource provides the certificate served by the HTTPS server
+type certificateSource interface {
+	GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	// watch keeps the certificate current until ctx is done
+	watch(ctx context.Context)
+	// load reads or generates a new certificate without serving it yet and
+	// returns a function that serves it, so that a reload can replace
+	// several certificates only once all of them loaded
+	load(ctx context.Context) (install func(), err error)
+}
+
+// generateTLSKeyandCert writes a new key and self-signed certificate for the
+// hosts of TlsSanList. Both are written to temporary files first and renamed
+// into place, so that a concurrent reload never reads a partial file.
+func generateTLSKeyandCert(TLSCertPath, TLSKeyPath, TlsSanList string) error {
+	key, err := rsa.GenerateKey(rand.Reader, DefaultKeyLength)
+	if err != nil {
+		return errors.Wrap(err, "error while generating RSA key pair")
+	}
+	defer ZeroizeRSAPrivateKey(key)
+
+	selfSignCert, err := selfSignedCertificate(key, TlsSanList, time.Now().AddDate(0, 0, ValidityDays))
+	defer ZeroizeByteArray(selfSignCert)
+	if err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: selfSignCert})
+
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	defer ZeroizeByteArray(keyDer)
+	if err != nil {
+		return errors.Wrap(err, "Unable to marshal private key")
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})
+	defer ZeroizeByteArray(keyPEM)
+
+	tlsKeyPath := filepath.Clean(TLSKeyPath)
+	keyTemp, err := writeTempFile(tlsKeyPath, keyPEM)
+	if err != nil {
+		return err
+	}
+	defer removeTempFile(keyTemp)
+
+	tlsCertPath := filepath.Clean(TLSCertPath)
+	certTemp, err := writeTempFile(tlsCertPath, certPEM)
+	if err != nil {
+		return err
+	}
+	defer removeTempFile(certTemp)
+
+	// a reload between the renames finds a key that does not match the
+	// certificate, keeps the previous certificate and retries on next check
+	if err := os.Rename(keyTemp, tlsKeyPath); err != nil {
+		return fmt.Errorf("could not move the private key to %s: %v", tlsKeyPath, err)
+	}
+	if err := os.Rename(certTemp, tlsCertPath); err != nil {
+		return fmt.Errorf("could not move the certificate to %s: %v", tlsCertPath, err)
+	}
+	return nil
+}
+
+// writeTempFile writes data to a new file with mode 0600 next to path and
+// returns its name. Renaming it replaces a symlink at path rather than the
+// file it points to.
+func writeTempFile(path string, data []byte) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", fmt.Errorf("could not create a temporary file for %s: %v", path, err)
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		removeTempFile(f.Name())
+		return "", fmt.Errorf("could not write %s: %v", f.Name(), err)
+	}
+	return f.Name(), nil
+}
+
+// removeTempFile removes a temporary file that was not renamed into place
+func removeTempFile(path string) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.WithError(err).Errorf("Error removing %s", path)
+	}
+}
+
+// selfSignedCertificate returns a DER encoded certificate for the hosts of
+// sanList signed by key, carrying the given extra extensions
+func selfSignedCertificate(key *rsa.PrivateKey, sanList string, notAfter time.Time, extensions ...pkix.Extension) ([]byte, error) {
+	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create serial number")
+	}
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			Organization: []string{selfSignedOrganization},
+		},
+
+		NotBefore:             time.Now(),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		ExtraExtensions:       extensions,
+	}
+
+	// add the san list for tls certificate
+	hosts := strings.Split(sanList, ",")
+	for _, h := range hosts {
+		h = strings.TrimSpace(h)
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Failed to create certificate")
+	}
+	return cert, nil
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package main
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateTLSKeyandCert(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+
+	// a symlink at the key path is replaced, not written through
+	target := filepath.Join(dir, "target")
+	if err := os.WriteFile(target, []byte("unchanged"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, keyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := generateTLSKeyandCert(certPath, keyPath, "localhost,127.0.0.1"); err != nil {
+			t.Fatalf("generateTLSKeyandCert: %v", err)
+		}
+		if _, err := tls.LoadX509KeyPair(certPath, keyPath); err != nil {
+			t.Fatalf("generated certificate and key do not load: %v", err)
+		}
+	}
+
+	if data, _ := os.ReadFile(target); string(data) != "unchanged" {
+		t.Fatal("generateTLSKeyandCert wrote through the symlink of the key path")
+	}
+	for _, path := range []string{certPath, keyPath} {
+		info, err := os.Lstat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.Mode().IsRegular() || info.Mode().Perm() != 0600 {
+			t.Fatalf("%s has mode %s, want a regular file with mode 0600", path, info.Mode())
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("generateTLSKeyandCert left temporary files: %v", entries)
+	}
+}
@@ -24,7 +24,6 @@ func setAttestationTokenHandler(svc service.Service, router *mux.Router, options
 	)
 
 	router.Handle("/token", getAttestationTokenHandler).Methods(http.MethodGet)
-	router.Handle("/token", optionsHandler).Methods(http.MethodOptions)
 
 	return nil
 }
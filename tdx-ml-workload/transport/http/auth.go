@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/intel/trustauthority-samples/tdxexample/auth"
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+	log "github.com/sirupsen/logrus"
+)
+
+// routeRoles is the role required per method and route template below the
+// API prefix. Routes that are not listed require the admin role.
+var routeRoles = map[string]auth.Role{
+	http.MethodGet + " /taa/v1/version":       auth.RoleUser,
+	http.MethodGet + " /taa/v1/models/status": auth.RoleUser,
+	http.MethodPost + " /taa/v1/execute":      auth.RoleUser,
+	http.MethodPost + " /taa/v1/quote":        auth.RoleUser,
+	http.MethodGet + " /taa/v1/token":         auth.RoleUser,
+	http.MethodPost + " /taa/v1/key":          auth.RoleAdmin,
+	http.MethodPost + " /taa/v1/decrypt":      auth.RoleAdmin,
+	http.MethodPost + " /taa/v1/models/load":  auth.RoleAdmin,
+	http.MethodPost + " /taa/v1/reset":        auth.RoleAdmin,
+	http.MethodPost + " /taa/v1/provision":    auth.RoleAdmin,
+	http.MethodPost + " /taa/v1/reload":       auth.RoleAdmin,
+}
+
+// requiredRole returns the role required for the route matched by r
+func requiredRole(r *http.Request) auth.Role {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return auth.RoleAdmin
+	}
+	tpl, err := route.GetPathTemplate()
+	if err != nil {
+		return auth.RoleAdmin
+	}
+	if role, ok := routeRoles[r.Method+" "+tpl]; ok {
+		return role
+	}
+	return auth.RoleAdmin
+}
+
+// authMiddleware authenticates requests with authenticator and checks that
+// the caller has the role the route requires. CORS preflight requests, which
+// browsers send without credentials, are answered before routing.
+func authMiddleware(authenticator auth.Authenticator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				log.WithError(err).WithField("request_id", service.RequestIDFromContext(r.Context())).Warn("Authentication failed")
+				w.Header().Set("WWW-Authenticate", `Bearer, ApiKey`)
+				errorEncoder(r.Context(), &service.HandledError{Code: http.StatusUnauthorized, Message: "Authentication required"}, w)
+				return
+			}
+
+			role := requiredRole(r)
+			if !principal.HasRole(role) {
+				log.WithFields(log.Fields{
+					"principal":  principal.Name,
+					"role":       role,
+					"request_id": service.RequestIDFromContext(r.Context()),
+				}).Warn("Authorization failed")
+				errorEncoder(r.Context(), &service.HandledError{Code: http.StatusForbidden, Message: "Role " + string(role) + " required"}, w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.ContextWithPrincipal(r.Context(), principal)))
+		})
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/intel/trustauthority-samples/tdxexample/auth"
+	"github.com/intel/trustauthority-samples/tdxexample/health"
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+)
+
+func (s *stubService) CheckHealth(context.Context, service.HealthRequest) (*service.HealthResponse, error) {
+	return &service.HealthResponse{Report: health.Report{Status: health.StatusPass}}, nil
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	authenticator := auth.NewAPIKeyAuthenticator(map[auth.Role]string{auth.RoleAdmin: "admin-key", auth.RoleUser: "user-key"})
+	h, err := NewHTTPHandler(&stubService{}, RoutesAll, authenticator, NewCORSPolicy(testCORSOptions))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		key      string
+		wantCode int
+	}{
+		{name: "no credentials", method: http.MethodGet, path: "/taa/v1/version", wantCode: http.StatusUnauthorized},
+		{name: "wrong key", method: http.MethodGet, path: "/taa/v1/version", key: "wrong", wantCode: http.StatusUnauthorized},
+		{name: "user route as user", method: http.MethodGet, path: "/taa/v1/version", key: "user-key", wantCode: http.StatusOK},
+		{name: "user route as admin", method: http.MethodGet, path: "/taa/v1/version", key: "admin-key", wantCode: http.StatusOK},
+		{name: "admin route as user", method: http.MethodPost, path: "/taa/v1/models/load", key: "user-key", wantCode: http.StatusForbidden},
+		// the stub service fails the load, the request passed authorization
+		{name: "admin route as admin", method: http.MethodPost, path: "/taa/v1/models/load", key: "admin-key", wantCode: http.StatusConflict},
+		{name: "preflight", method: http.MethodOptions, path: "/taa/v1/models/load", wantCode: http.StatusOK},
+		{name: "health probe", method: http.MethodGet, path: "/readyz", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(auth.APIKeyHeader, tt.key)
+			}
+			if tt.method == http.MethodOptions {
+				// browsers send preflights without credentials
+				req.Header.Set("Origin", "https://app.example.com")
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("status code %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("401 without WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestRequiredRole(t *testing.T) {
+	var role auth.Role
+	router := mux.NewRouter()
+	handler := func(_ http.ResponseWriter, r *http.Request) { role = requiredRole(r) }
+	router.HandleFunc("/taa/v1/version", handler)
+	router.HandleFunc("/taa/v1/unlisted", handler)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   auth.Role
+	}{
+		{name: "listed route", method: http.MethodGet, path: "/taa/v1/version", want: auth.RoleUser},
+		// routes without an entry require the admin role
+		{name: "listed route with other method", method: http.MethodPost, path: "/taa/v1/version", want: auth.RoleAdmin},
+		{name: "unlisted route", method: http.MethodGet, path: "/taa/v1/unlisted", want: auth.RoleAdmin},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			role = ""
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+			if role != tt.want {
+				t.Fatalf("route requires role %q, want %q", role, tt.want)
+			}
+		})
+	}
+
+	if role := requiredRole(httptest.NewRequest(http.MethodGet, "/taa/v1/version", nil)); role != auth.RoleAdmin {
+		t.Fatalf("request without route requires role %q, want %q", role, auth.RoleAdmin)
+	}
+}
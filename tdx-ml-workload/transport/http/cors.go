@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package http
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/handlers"
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+)
+
+// CORSOptions configures cross-origin access to the REST API
+type CORSOptions struct {
+	// AllowedOrigins may contain "*" to allow any origin. Cross-origin
+	// requests are not allowed if empty.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is the time in seconds browsers may cache preflight responses
+	MaxAge int
+}
+
+// CORSPolicy applies CORSOptions that can be replaced at runtime
+type CORSPolicy struct {
+	mu         sync.RWMutex
+	middleware func(http.Handler) http.Handler
+}
+
+// NewCORSPolicy returns a policy applying opts
+func NewCORSPolicy(opts CORSOptions) *CORSPolicy {
+	p := &CORSPolicy{}
+	p.Set(opts)
+	return p
+}
+
+// Set replaces the options for subsequent requests
+func (p *CORSPolicy) Set(opts CORSOptions) {
+	middleware := corsHandler(opts)
+	p.mu.Lock()
+	p.middleware = middleware
+	p.mu.Unlock()
+}
+
+// handler applies the current options to requests to next
+func (p *CORSPolicy) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.RLock()
+		middleware := p.middleware
+		p.mu.RUnlock()
+		middleware(next).ServeHTTP(w, r)
+	})
+}
+
+// corsHandler answers preflight requests for all routes and adds the CORS
+// headers to responses to allowed origins. Without allowed origins no CORS
+// headers are sent, so browsers block cross-origin requests.
+func corsHandler(opts CORSOptions) func(http.Handler) http.Handler {
+	if len(opts.AllowedOrigins) == 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+
+	corsOpts := []handlers.CORSOption{
+		handlers.AllowedOrigins(opts.AllowedOrigins),
+		handlers.AllowedMethods(opts.AllowedMethods),
+		handlers.AllowedHeaders(opts.AllowedHeaders),
+		handlers.ExposedHeaders([]string{service.RequestIDHeader}),
+		handlers.MaxAge(opts.MaxAge),
+	}
+	if opts.AllowCredentials {
+		corsOpts = append(corsOpts, handlers.AllowCredentials())
+	}
+	return handlers.CORS(corsOpts...)
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+)
+
+var testCORSOptions = CORSOptions{
+	AllowedOrigins: []string{"https://app.example.com"},
+	AllowedMethods: []string{http.MethodGet, http.MethodPost},
+	AllowedHeaders: []string{"Content-Type", "X-Api-Key"},
+	MaxAge:         600,
+}
+
+func TestCORSUnconfigured(t *testing.T) {
+	h, err := NewHTTPHandler(&stubService{}, RoutesAll, nil, NewCORSPolicy(CORSOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/taa/v1/version", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status code %d, want %d", w.Code, http.StatusOK)
+	}
+	for name := range w.Header() {
+		if strings.HasPrefix(name, "Access-Control-") {
+			t.Fatalf("unexpected CORS header %s", name)
+		}
+	}
+}
+
+func TestCORSOrigin(t *testing.T) {
+	h, err := NewHTTPHandler(&stubService{}, RoutesAll, nil, NewCORSPolicy(testCORSOptions))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		origin     string
+		wantOrigin string
+	}{
+		{name: "allowed origin", origin: "https://app.example.com", wantOrigin: "https://app.example.com"},
+		{name: "disallowed origin", origin: "https://other.example.com"},
+		{name: "same origin", origin: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/taa/v1/version", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Fatalf("Access-Control-Allow-Origin is %q, want %q", got, tt.wantOrigin)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+				t.Fatalf("Access-Control-Allow-Credentials is %q without credentials allowed", got)
+			}
+			if tt.wantOrigin != "" && w.Header().Get("Access-Control-Expose-Headers") != http.CanonicalHeaderKey(service.RequestIDHeader) {
+				t.Fatalf("Access-Control-Expose-Headers is %q, want %q", w.Header().Get("Access-Control-Expose-Headers"), service.RequestIDHeader)
+			}
+		})
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	opts := testCORSOptions
+	opts.AllowCredentials = true
+	h, err := NewHTTPHandler(&stubService{}, RoutesAll, nil, NewCORSPolicy(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		origin      string
+		method      string
+		wantAllowed bool
+	}{
+		{name: "allowed", origin: "https://app.example.com", method: http.MethodPost, wantAllowed: true},
+		{name: "disallowed origin", origin: "https://other.example.com", method: http.MethodPost},
+		{name: "disallowed method", origin: "https://app.example.com", method: http.MethodDelete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/taa/v1/models/load", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", tt.method)
+			req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			allowed := w.Header().Get("Access-Control-Allow-Origin") == tt.origin
+			if allowed != tt.wantAllowed {
+				t.Fatalf("preflight allowed %v, want %v: %d %v", allowed, tt.wantAllowed, w.Code, w.Header())
+			}
+			if !tt.wantAllowed {
+				return
+			}
+			if w.Code != http.StatusOK {
+				t.Fatalf("status code %d, want %d", w.Code, http.StatusOK)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+				t.Fatalf("Access-Control-Allow-Credentials is %q, want true", got)
+			}
+			if got := w.Header().Get("Access-Control-Max-Age"); got != "600" {
+				t.Fatalf("Access-Control-Max-Age is %q, want 600", got)
+			}
+		})
+	}
+}
+
+func TestCORSPolicySet(t *testing.T) {
+	policy := NewCORSPolicy(testCORSOptions)
+	h, err := NewHTTPHandler(&stubService{}, RoutesAll, nil, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	allowedOrigin := func(origin string) string {
+		req := httptest.NewRequest(http.MethodGet, "/taa/v1/version", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	opts := testCORSOptions
+	opts.AllowedOrigins = []string{"https://other.example.com"}
+	policy.Set(opts)
+
+	// the handler applies the new options without being created again
+	if got := allowedOrigin("https://other.example.com"); got != "https://other.example.com" {
+		t.Fatalf("Access-Control-Allow-Origin is %q for the new origin", got)
+	}
+	if got := allowedOrigin("https://app.example.com"); got != "" {
+		t.Fatalf("Access-Control-Allow-Origin is %q for the replaced origin", got)
+	}
+}
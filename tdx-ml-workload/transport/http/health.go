@@ -0,0 +1,44 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	httpTransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/intel/trustauthority-samples/tdxexample/health"
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+)
+
+// setHealthHandler registers the health probes. They are served outside of
+// the /taa/v1 prefix and return 200 when all checks pass and 503 otherwise.
+func setHealthHandler(svc service.Service, router *mux.Router, options []httpTransport.ServerOption) error {
+
+	probes := map[string][]health.Probe{
+		"/healthz": nil,
+		"/livez":   {health.Liveness},
+		"/readyz":  {health.Readiness},
+	}
+
+	for path, probe := range probes {
+		healthHandler := httpTransport.NewServer(
+			makeHealthHTTPEndpoint(svc, probe),
+			httpTransport.NopRequestDecoder,
+			httpTransport.EncodeJSONResponse,
+			options...,
+		)
+		router.Handle(path, healthHandler).Methods(http.MethodGet)
+	}
+	return nil
+}
+
+func makeHealthHTTPEndpoint(svc service.Service, probes []health.Probe) endpoint.Endpoint {
+	return func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return svc.CheckHealth(ctx, service.HealthRequest{Probes: probes})
+	}
+}
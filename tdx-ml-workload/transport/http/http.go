@@ -12,8 +12,11 @@ import (
 	httpTransport "github.com/go-kit/kit/transport/http"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/intel/trustauthority-samples/tdxexample/auth"
 	"github.com/intel/trustauthority-samples/tdxexample/service"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 const (
@@ -24,7 +27,11 @@ const (
 	HTTPHeaderKeyAttestationType   = "Attestation-Type"
 )
 
-func NewHTTPHandler(svc service.Service) (http.Handler, error) {
+// NewHTTPHandler returns the handler of the REST API serving routes. Requests
+// below /taa/v1 are authenticated with authenticator and authorized per
+// route, a nil authenticator disables authentication. Cross-origin requests
+// are allowed as configured by cors.
+func NewHTTPHandler(svc service.Service, routes Routes, authenticator auth.Authenticator, cors *CORSPolicy) (http.Handler, error) {
 	r := mux.NewRouter()
 	r.SkipClean(true)
 
@@ -35,6 +42,10 @@ func NewHTTPHandler(svc service.Service) (http.Handler, error) {
 	{
 		prefix := r.PathPrefix("/taa/v1")
 		sr := prefix.Subrouter()
+		sr.Use(routeFilter(routes))
+		if authenticator != nil {
+			sr.Use(authMiddleware(authenticator))
+		}
 
 		myHandlers := []func(service.Service, *mux.Router, []httpTransport.ServerOption) error{
 			setGetVersionHandler,
@@ -43,6 +54,7 @@ func NewHTTPHandler(svc service.Service) (http.Handler, error) {
 			setKeyHandler,
 			setAttestationTokenHandler,
 			setProvisionHandler,
+			setReloadHandler,
 		}
 
 		for _, handler := range myHandlers {
@@ -52,26 +64,45 @@ func NewHTTPHandler(svc service.Service) (http.Handler, error) {
 		}
 	}
 
+	if err := setHealthHandler(svc, r, options); err != nil {
+		return nil, err
+	}
+
 	h := handlers.RecoveryHandler(
 		handlers.RecoveryLogger(log.StandardLogger()),
 		handlers.PrintRecoveryStack(true),
 	)(
 		handlers.CombinedLoggingHandler(
 			log.StandardLogger().Writer(),
-			r,
+			// starts the request span, continuing a W3C traceparent if present
+			otelhttp.NewHandler(cors.handler(requestIDHandler(r)), "http.server", otelhttp.WithSpanNameFormatter(spanName)),
 		),
 	)
 
 	return h, nil
 }
 
-func errorEncoder(_ context.Context, err error, w http.ResponseWriter) {
-	if handledError, ok := err.(*service.HandledError); ok {
+// spanName names request spans by method and path, the routes have no path
+// variables
+func spanName(_ string, r *http.Request) string {
+	return r.Method + " " + r.URL.Path
+}
+
+// errorEncoder writes the status code of err, which may be wrapped, its
+// message and the request ID
+func errorEncoder(ctx context.Context, err error, w http.ResponseWriter) {
+	resp := errorWrapper{Error: err.Error(), RequestID: service.RequestIDFromContext(ctx)}
+	var handledError *service.HandledError
+	var validationError *service.ValidationError
+	if errors.As(err, &handledError) {
 		w.WriteHeader(handledError.Code)
+	} else if errors.As(err, &validationError) {
+		w.WriteHeader(validationError.StatusCode())
+		resp.Details = validationError.Fields
 	} else {
-		w.WriteHeader(errToCode(err))
+		w.WriteHeader(errToCode(errors.Cause(err)))
 	}
-	if err := json.NewEncoder(w).Encode(errorWrapper{Error: err.Error()}); err != nil {
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		log.WithError(err).Error("Failed to encode error")
 	}
 }
@@ -87,5 +118,7 @@ func errToCode(err error) int {
 }
 
 type errorWrapper struct {
-	Error string `json:"error"`
+	Error     string               `json:"error"`
+	Details   []service.FieldError `json:"details,omitempty"`
+	RequestID string               `json:"request_id,omitempty"`
 }
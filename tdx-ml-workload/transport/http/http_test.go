@@ -0,0 +1,82 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+	"github.com/pkg/errors"
+)
+
+func TestErrorEncoder(t *testing.T) {
+	validationError := &service.ValidationError{Fields: []service.FieldError{
+		{Field: "age", Message: "is required"},
+		{Field: "bmi", Message: "must be a finite number"},
+	}}
+
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    int
+		wantError   string
+		wantDetails []service.FieldError
+	}{
+		{
+			name:        "validation error",
+			err:         validationError,
+			wantCode:    http.StatusBadRequest,
+			wantError:   "Invalid request: age is required, bmi must be a finite number",
+			wantDetails: validationError.Fields,
+		},
+		{
+			name:      "handled error",
+			err:       &service.HandledError{Code: http.StatusConflict, Message: "No key transfer URL"},
+			wantCode:  http.StatusConflict,
+			wantError: "409: No key transfer URL",
+		},
+		{
+			name:      "wrapped handled error",
+			err:       errors.Wrap(&service.HandledError{Code: http.StatusBadGateway, Message: "KBS failed"}, "could not load model"),
+			wantCode:  http.StatusBadGateway,
+			wantError: "could not load model: 502: KBS failed",
+		},
+		{
+			name:      "wrapped transport error",
+			err:       errors.Wrap(ErrJsonDecodeFailed, "decode"),
+			wantCode:  http.StatusBadRequest,
+			wantError: "decode: " + ErrJsonDecodeFailed.Error(),
+		},
+		{
+			name:      "unknown error",
+			err:       errors.New("boom"),
+			wantCode:  http.StatusInternalServerError,
+			wantError: "boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			errorEncoder(context.Background(), tt.err, w)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("status code %d, want %d", w.Code, tt.wantCode)
+			}
+			var body errorWrapper
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if body.Error != tt.wantError || !reflect.DeepEqual(body.Details, tt.wantDetails) {
+				t.Fatalf("body %+v, want error %q and details %v", body, tt.wantError, tt.wantDetails)
+			}
+		})
+	}
+}
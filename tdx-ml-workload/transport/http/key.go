@@ -26,7 +26,6 @@ func setKeyHandler(svc service.Service, router *mux.Router, options []httpTransp
 	)
 
 	router.Handle("/key", getKeyHandler).Methods(http.MethodPost)
-	router.Handle("/key", optionsHandler).Methods(http.MethodOptions)
 
 	return nil
 }
@@ -26,7 +26,24 @@ func setModelHandler(svc service.Service, router *mux.Router, options []httpTran
 	)
 
 	router.Handle("/decrypt", decryptHandler).Methods(http.MethodPost)
-	router.Handle("/decrypt", optionsHandler).Methods(http.MethodOptions)
+
+	loadHandler := httpTransport.NewServer(
+		makeLoadModelHTTPEndpoint(svc),
+		httpTransport.NopRequestDecoder,
+		httpTransport.EncodeJSONResponse,
+		options...,
+	)
+
+	router.Handle("/models/load", loadHandler).Methods(http.MethodPost)
+
+	statusHandler := httpTransport.NewServer(
+		makeGetModelStatusHTTPEndpoint(svc),
+		httpTransport.NopRequestDecoder,
+		httpTransport.EncodeJSONResponse,
+		options...,
+	)
+
+	router.Handle("/models/status", statusHandler).Methods(http.MethodGet)
 
 	executeHandler := httpTransport.NewServer(
 		makeExecuteHTTPEndpoint(svc),
@@ -36,7 +53,6 @@ func setModelHandler(svc service.Service, router *mux.Router, options []httpTran
 	)
 
 	router.Handle("/execute", executeHandler).Methods(http.MethodPost)
-	router.Handle("/execute", optionsHandler).Methods(http.MethodOptions)
 
 	resetHandler := httpTransport.NewServer(
 		makeResetHTTPEndpoint(svc),
@@ -46,7 +62,6 @@ func setModelHandler(svc service.Service, router *mux.Router, options []httpTran
 	)
 
 	router.Handle("/reset", resetHandler).Methods(http.MethodPost)
-	router.Handle("/reset", optionsHandler).Methods(http.MethodOptions)
 
 	return nil
 }
@@ -58,6 +73,18 @@ func makeDecryptHTTPEndpoint(svc service.Service) endpoint.Endpoint {
 	}
 }
 
+func makeLoadModelHTTPEndpoint(svc service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		return svc.LoadModel(ctx)
+	}
+}
+
+func makeGetModelStatusHTTPEndpoint(svc service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return svc.GetModelStatus(ctx)
+	}
+}
+
 func makeExecuteHTTPEndpoint(svc service.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(service.InferRequest)
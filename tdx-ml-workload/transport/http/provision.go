@@ -22,7 +22,15 @@ func setProvisionHandler(svc service.Service, router *mux.Router, options []http
 	)
 
 	router.Handle("/provision", getProvisionHandler).Methods(http.MethodPost)
-	router.Handle("/provision", optionsHandler).Methods(http.MethodOptions)
+
+	provisionStatusHandler := httpTransport.NewServer(
+		makeGetProvisionStatusHTTPEndpoint(svc),
+		httpTransport.NopRequestDecoder,
+		httpTransport.EncodeJSONResponse,
+		options...,
+	)
+
+	router.Handle("/provision", provisionStatusHandler).Methods(http.MethodGet)
 
 	return nil
 }
@@ -34,6 +42,12 @@ func makeProvisionHTTPEndpoint(svc service.Service) endpoint.Endpoint {
 	}
 }
 
+func makeGetProvisionStatusHTTPEndpoint(svc service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return svc.GetProvisionStatus(ctx)
+	}
+}
+
 func decodeProvisionHTTPRequest(_ context.Context, r *http.Request) (interface{}, error) {
 
 	if r.Header.Get(HTTPHeaderKeyContentType) != HTTPHeaderValueApplicationJson {
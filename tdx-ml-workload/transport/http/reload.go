@@ -0,0 +1,35 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-kit/kit/endpoint"
+	httpTransport "github.com/go-kit/kit/transport/http"
+	"github.com/gorilla/mux"
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+)
+
+func setReloadHandler(svc service.Service, router *mux.Router, options []httpTransport.ServerOption) error {
+
+	reloadHandler := httpTransport.NewServer(
+		makeReloadHTTPEndpoint(svc),
+		httpTransport.NopRequestDecoder,
+		httpTransport.EncodeJSONResponse,
+		options...,
+	)
+
+	router.Handle("/reload", reloadHandler).Methods(http.MethodPost)
+
+	return nil
+}
+
+func makeReloadHTTPEndpoint(svc service.Service) endpoint.Endpoint {
+	return func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return svc.Reload(ctx)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package http
+
+import (
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+)
+
+// maxRequestIDLength bounds client supplied request IDs, which end up in logs
+// and in requests to the KBS
+const maxRequestIDLength = 128
+
+// requestIDHandler takes the request ID from the X-Request-ID header, or
+// generates one if it is missing or invalid, puts it in the request context
+// and returns it in the response headers
+func requestIDHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(service.RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(service.RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(service.ContextWithRequestID(r.Context(), requestID)))
+	})
+}
+
+// validRequestID accepts printable ASCII only, so that request IDs cannot
+// inject log lines or headers
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/intel/trustauthority-samples/tdxexample/service"
+	"github.com/intel/trustauthority-samples/tdxexample/version"
+)
+
+// stubService implements the calls used by the tests, all others panic. It
+// records the request ID of the last call.
+type stubService struct {
+	service.Service
+	requestID string
+}
+
+func (s *stubService) GetVersion(ctx context.Context) (*version.ServiceVersion, error) {
+	s.requestID = service.RequestIDFromContext(ctx)
+	return &version.ServiceVersion{}, nil
+}
+
+func (s *stubService) LoadModel(ctx context.Context) (*service.LoadModelResponse, error) {
+	s.requestID = service.RequestIDFromContext(ctx)
+	return nil, &service.HandledError{Code: http.StatusConflict, Message: "No key transfer URL"}
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		keep      bool
+	}{
+		{name: "absent"},
+		{name: "valid", requestID: "client-request-1", keep: true},
+		{name: "longest", requestID: strings.Repeat("a", maxRequestIDLength), keep: true},
+		{name: "oversized", requestID: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "space", requestID: "client request"},
+		{name: "control character", requestID: "client\x7f"},
+		{name: "non-ascii", requestID: "clïent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &stubService{}
+			h, err := NewHTTPHandler(svc, RoutesAll, nil, NewCORSPolicy(CORSOptions{}))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/taa/v1/version", nil)
+			if tt.requestID != "" {
+				req.Header.Set(service.RequestIDHeader, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			requestID := w.Header().Get(service.RequestIDHeader)
+			if tt.keep {
+				if requestID != tt.requestID {
+					t.Fatalf("returned request ID %q, want %q", requestID, tt.requestID)
+				}
+			} else if _, err := uuid.Parse(requestID); err != nil {
+				t.Fatalf("returned request ID %q, want a generated UUID", requestID)
+			}
+			if svc.requestID != requestID {
+				t.Fatalf("service got request ID %q, want %q", svc.requestID, requestID)
+			}
+		})
+	}
+}
+
+func TestRequestIDInErrorResponse(t *testing.T) {
+	svc := &stubService{}
+	h, err := NewHTTPHandler(svc, RoutesAll, nil, NewCORSPolicy(CORSOptions{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/taa/v1/models/load", nil)
+	req.Header.Set(service.RequestIDHeader, "client-request-1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status code %d, want %d", w.Code, http.StatusConflict)
+	}
+	var body errorWrapper
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.RequestID != "client-request-1" || w.Header().Get(service.RequestIDHeader) != "client-request-1" {
+		t.Fatalf("error response %+v with header %q does not carry the request ID", body, w.Header().Get(service.RequestIDHeader))
+	}
+}
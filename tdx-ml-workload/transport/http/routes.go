@@ -0,0 +1,52 @@
+/*
+ * Copyright (C) 2024 Intel Corporation
+ * SPDX-License-Identifier: BSD-3-Clause
+ */
+package http
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/intel/trustauthority-samples/tdxexample/auth"
+)
+
+// Routes selects the API routes a handler serves, so that operational routes
+// can be served on a listener of their own
+type Routes int
+
+const (
+	// RoutesAll serves all routes on a single listener
+	RoutesAll Routes = iota
+	// RoutesPublic serves the inference and attestation routes, which
+	// require the user role
+	RoutesPublic
+	// RoutesAdmin serves the operational routes, which require the admin
+	// role
+	RoutesAdmin
+)
+
+// serves reports whether routes include the routes requiring role
+func (routes Routes) serves(role auth.Role) bool {
+	switch routes {
+	case RoutesPublic:
+		return role == auth.RoleUser
+	case RoutesAdmin:
+		return role == auth.RoleAdmin
+	}
+	return true
+}
+
+// routeFilter answers requests to routes that are not served with 404, as if
+// the route did not exist, before the caller is authenticated
+func routeFilter(routes Routes) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !routes.serves(requiredRole(r)) {
+				http.NotFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}